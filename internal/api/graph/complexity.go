@@ -0,0 +1,54 @@
+package graph
+
+import (
+	"math"
+
+	"github.com/AEKDA/ozon_task/internal/api/graph/model"
+	"github.com/AEKDA/ozon_task/internal/repository/cursor"
+)
+
+// NewComplexity prices connection fields by the number of nodes they may
+// return, so the cost of nested connections multiplies.
+func NewComplexity() ComplexityRoot {
+	var c ComplexityRoot
+
+	c.Query.Posts = func(childComplexity int, first *int, after *string, last *int, before *string, orderBy model.PostOrder, filter *model.PostFilter) int {
+		return pageComplexity(childComplexity, first, last)
+	}
+	c.Query.Search = func(childComplexity int, query string, first *int, after *string) int {
+		return pageComplexity(childComplexity, first, nil)
+	}
+	c.Post.Comments = func(childComplexity int, first *int, after *string, last *int, before *string, rootsOnly bool) int {
+		return pageComplexity(childComplexity, first, last)
+	}
+	c.Post.Revisions = func(childComplexity int, first *int, after *string) int {
+		return pageComplexity(childComplexity, first, nil)
+	}
+	c.Comment.Replies = func(childComplexity int, first *int, after *string, last *int, before *string) int {
+		return pageComplexity(childComplexity, first, last)
+	}
+	c.Comment.Revisions = func(childComplexity int, first *int, after *string) int {
+		return pageComplexity(childComplexity, first, nil)
+	}
+
+	return c
+}
+
+func pageComplexity(childComplexity int, first *int, last *int) int {
+	size := cursor.DefaultPageSize
+	switch {
+	case first != nil:
+		size = *first
+	case last != nil:
+		size = *last
+	}
+	if size < 1 {
+		return 1
+	}
+
+	// Saturate instead of overflowing into an affordable negative cost.
+	if childComplexity > (math.MaxInt-1)/size {
+		return math.MaxInt
+	}
+	return 1 + childComplexity*size
+}
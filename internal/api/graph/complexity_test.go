@@ -0,0 +1,48 @@
+package graph
+
+import (
+	"math"
+	"testing"
+
+	"github.com/AEKDA/ozon_task/internal/repository/cursor"
+)
+
+func TestPageComplexity(t *testing.T) {
+	ten, zero := 10, 0
+
+	tests := []struct {
+		name  string
+		child int
+		first *int
+		last  *int
+		want  int
+	}{
+		{name: "first", child: 3, first: &ten, want: 31},
+		{name: "last", child: 3, last: &ten, want: 31},
+		{name: "default page size", child: 3, want: 1 + 3*cursor.DefaultPageSize},
+		{name: "empty page", child: 3, first: &zero, want: 1},
+		{name: "saturates", child: math.MaxInt / 5, first: &ten, want: math.MaxInt},
+		{name: "saturated child", child: math.MaxInt, first: &ten, want: math.MaxInt},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pageComplexity(tt.child, tt.first, tt.last); got != tt.want {
+				t.Errorf("pageComplexity(%d) = %d, want %d", tt.child, got, tt.want)
+			}
+		})
+	}
+
+	// Nested connections keep the saturated cost instead of wrapping around
+	// to a negative one.
+	cost := 1
+	for i := 0; i < 20; i++ {
+		cost = pageComplexity(cost, &ten, nil)
+		if cost < 1 {
+			t.Fatalf("cost wrapped around to %d at depth %d", cost, i+1)
+		}
+	}
+	if cost != math.MaxInt {
+		t.Errorf("cost of 20 nested connections is %d, want %d", cost, math.MaxInt)
+	}
+}
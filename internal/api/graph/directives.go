@@ -5,11 +5,17 @@ import (
 	"fmt"
 
 	"github.com/99designs/gqlgen/graphql"
+	"github.com/AEKDA/ozon_task/internal/api/graph/model"
+	"github.com/AEKDA/ozon_task/internal/auth"
+	"github.com/AEKDA/ozon_task/internal/service"
 )
 
 func LengthDirective(ctx context.Context, obj interface{}, next graphql.Resolver, min *int, max *int) (res interface{}, err error) {
 
 	res, err = next(ctx)
+	if err != nil {
+		return nil, err
+	}
 
 	str, ok := res.(string)
 	if !ok {
@@ -17,11 +23,29 @@ func LengthDirective(ctx context.Context, obj interface{}, next graphql.Resolver
 	}
 
 	if max != nil && len([]rune(str)) > *max {
-		return nil, fmt.Errorf("field exceeds the maximum length of %d", max)
+		return nil, service.Errorf(service.CodeValidation, "field exceeds the maximum length of %d", *max)
 	}
 	if min != nil && len([]rune(str)) < *min {
-		return nil, fmt.Errorf("field is below the minimum length of %d", min)
+		return nil, service.Errorf(service.CodeValidation, "field is below the minimum length of %d", *min)
 	}
 
 	return
 }
+
+// AuthDirective rejects anonymous callers. Ownership depends on the object
+// being changed, so for OWNER fields it is checked by the PostService.
+func AuthDirective(ctx context.Context, obj interface{}, next graphql.Resolver, requires model.AuthRequirement) (res interface{}, err error) {
+	principal := auth.ForContext(ctx)
+	if principal == nil {
+		return nil, auth.ErrUnauthenticated
+	}
+
+	switch {
+	case requires == model.AuthRequirementModerator && !principal.IsModerator():
+		return nil, &service.Error{Code: service.CodeForbidden, Message: "only moderators can do this"}
+	case requires == model.AuthRequirementAdmin && !principal.IsAdmin():
+		return nil, &service.Error{Code: service.CodeForbidden, Message: "only admins can do this"}
+	}
+
+	return next(ctx)
+}
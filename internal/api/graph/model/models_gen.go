@@ -3,36 +3,56 @@
 package model
 
 import (
+	"fmt"
+	"io"
+	"strconv"
 	"time"
 )
 
+type PostEvent interface {
+	IsPostEvent()
+}
+
+type SearchResult interface {
+	IsSearchResult()
+}
+
 type AddCommentInput struct {
 	PostID  int64  `json:"postId"`
 	Content string `json:"content"`
-	Author  string `json:"author"`
 }
 
 type AddPostInput struct {
 	Title         string `json:"title"`
 	Content       string `json:"content"`
-	Author        string `json:"author"`
 	AllowComments bool   `json:"allowComments"`
 }
 
 type AddReplyInput struct {
 	CommentID int64  `json:"commentId"`
 	Content   string `json:"content"`
-	Author    string `json:"author"`
 }
 
 type Comment struct {
-	ID        int64     `json:"id"`
-	Content   string    `json:"content"`
-	Author    string    `json:"author"`
-	CreatedAt time.Time `json:"createdAt"`
-	ReplyTo   *int64    `json:"reply_to,omitempty"`
+	ID         int64                     `json:"id"`
+	PostID     int64                     `json:"postId"`
+	Content    string                    `json:"content"`
+	AuthorID   int64                     `json:"authorId"`
+	Author     User                      `json:"author"`
+	CreatedAt  time.Time                 `json:"createdAt"`
+	EditedAt   *time.Time                `json:"editedAt,omitempty"`
+	IsDeleted  bool                      `json:"isDeleted"`
+	IsHidden   bool                      `json:"isHidden"`
+	ReplyTo    *int64                    `json:"reply_to,omitempty"`
+	ReplyCount int                       `json:"replyCount"`
+	Depth      int                       `json:"depth"`
+	Parent     *Comment                  `json:"parent,omitempty"`
+	Replies    CommentConnection         `json:"replies"`
+	Revisions  CommentRevisionConnection `json:"revisions"`
 }
 
+func (Comment) IsSearchResult() {}
+
 type CommentConnection struct {
 	Edges    []CommentEdge `json:"edges"`
 	PageInfo PageInfo      `json:"pageInfo"`
@@ -43,37 +63,244 @@ type CommentEdge struct {
 	Node   Comment `json:"node"`
 }
 
+type CommentRevision struct {
+	Revision  int       `json:"revision"`
+	Content   string    `json:"content"`
+	AuthorID  int64     `json:"authorId"`
+	Author    User      `json:"author"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type CommentRevisionConnection struct {
+	Edges    []CommentRevisionEdge `json:"edges"`
+	PageInfo PageInfo              `json:"pageInfo"`
+}
+
+type CommentRevisionEdge struct {
+	Cursor string          `json:"cursor"`
+	Node   CommentRevision `json:"node"`
+}
+
+type CommentsToggled struct {
+	Post          Post `json:"post"`
+	AllowComments bool `json:"allowComments"`
+}
+
+func (CommentsToggled) IsPostEvent() {}
+
+type EditCommentInput struct {
+	ID      int64  `json:"id"`
+	Content string `json:"content"`
+}
+
 type Mutation struct {
 }
 
 type PageInfo struct {
-	HasNextPage bool   `json:"hasNextPage"`
-	StartCursor string `json:"startCursor"`
-	EndCursor   string `json:"endCursor"`
+	HasNextPage     bool   `json:"hasNextPage"`
+	HasPreviousPage bool   `json:"hasPreviousPage"`
+	StartCursor     string `json:"startCursor"`
+	EndCursor       string `json:"endCursor"`
 }
 
 type Post struct {
-	ID            int64             `json:"id"`
-	Title         string            `json:"title"`
-	Content       string            `json:"content"`
-	Author        string            `json:"author"`
-	CreatedAt     time.Time         `json:"createdAt"`
-	AllowComments bool              `json:"allowComments"`
-	Comments      CommentConnection `json:"comments"`
+	ID              int64                  `json:"id"`
+	Title           string                 `json:"title"`
+	Content         string                 `json:"content"`
+	AuthorID        int64                  `json:"authorId"`
+	Author          User                   `json:"author"`
+	CreatedAt       time.Time              `json:"createdAt"`
+	EditedAt        *time.Time             `json:"editedAt,omitempty"`
+	AllowComments   bool                   `json:"allowComments"`
+	Locked          bool                   `json:"locked"`
+	PinnedCommentID *int64                 `json:"pinnedCommentId,omitempty"`
+	PinnedComment   *Comment               `json:"pinnedComment,omitempty"`
+	ViewerCount     int                    `json:"viewerCount"`
+	Comments        CommentConnection      `json:"comments"`
+	Revisions       PostRevisionConnection `json:"revisions"`
 }
 
+func (Post) IsSearchResult() {}
+
 type PostConnection struct {
 	Edges    []PostEdge `json:"edges"`
 	PageInfo PageInfo   `json:"pageInfo"`
 }
 
+type PostCreated struct {
+	Post Post `json:"post"`
+}
+
+func (PostCreated) IsPostEvent() {}
+
+type PostDeleted struct {
+	PostID int64 `json:"postId"`
+}
+
+func (PostDeleted) IsPostEvent() {}
+
 type PostEdge struct {
 	Cursor string `json:"cursor"`
 	Node   Post   `json:"node"`
 }
 
+type PostFilter struct {
+	AuthorID      *int64     `json:"authorId,omitempty"`
+	CreatedAfter  *time.Time `json:"createdAfter,omitempty"`
+	CreatedBefore *time.Time `json:"createdBefore,omitempty"`
+	AllowComments *bool      `json:"allowComments,omitempty"`
+}
+
+type PostRevision struct {
+	Revision  int       `json:"revision"`
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	AuthorID  int64     `json:"authorId"`
+	Author    User      `json:"author"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type PostRevisionConnection struct {
+	Edges    []PostRevisionEdge `json:"edges"`
+	PageInfo PageInfo           `json:"pageInfo"`
+}
+
+type PostRevisionEdge struct {
+	Cursor string       `json:"cursor"`
+	Node   PostRevision `json:"node"`
+}
+
+type PostUpdated struct {
+	Post Post `json:"post"`
+}
+
+func (PostUpdated) IsPostEvent() {}
+
 type Query struct {
 }
 
+type RegisterUserInput struct {
+	Name string `json:"name"`
+}
+
+type ReplyNotification struct {
+	Comment Comment `json:"comment"`
+	Reply   Comment `json:"reply"`
+}
+
+type SearchConnection struct {
+	Edges    []SearchEdge `json:"edges"`
+	PageInfo PageInfo     `json:"pageInfo"`
+}
+
+type SearchEdge struct {
+	Cursor  string       `json:"cursor"`
+	Node    SearchResult `json:"node"`
+	Snippet string       `json:"snippet"`
+}
+
 type Subscription struct {
 }
+
+type UpdatePostInput struct {
+	ID      int64   `json:"id"`
+	Title   *string `json:"title,omitempty"`
+	Content *string `json:"content,omitempty"`
+}
+
+type User struct {
+	ID          int64      `json:"id"`
+	Name        string     `json:"name"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	BannedUntil *time.Time `json:"bannedUntil,omitempty"`
+}
+
+type AuthRequirement string
+
+const (
+	AuthRequirementAuthenticated AuthRequirement = "AUTHENTICATED"
+	AuthRequirementOwner         AuthRequirement = "OWNER"
+	AuthRequirementModerator     AuthRequirement = "MODERATOR"
+	AuthRequirementAdmin         AuthRequirement = "ADMIN"
+)
+
+var AllAuthRequirement = []AuthRequirement{
+	AuthRequirementAuthenticated,
+	AuthRequirementOwner,
+	AuthRequirementModerator,
+	AuthRequirementAdmin,
+}
+
+func (e AuthRequirement) IsValid() bool {
+	switch e {
+	case AuthRequirementAuthenticated, AuthRequirementOwner, AuthRequirementModerator, AuthRequirementAdmin:
+		return true
+	}
+	return false
+}
+
+func (e AuthRequirement) String() string {
+	return string(e)
+}
+
+func (e *AuthRequirement) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = AuthRequirement(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid AuthRequirement", str)
+	}
+	return nil
+}
+
+func (e AuthRequirement) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type PostOrder string
+
+const (
+	PostOrderCreatedAtDesc     PostOrder = "CREATED_AT_DESC"
+	PostOrderCreatedAtAsc      PostOrder = "CREATED_AT_ASC"
+	PostOrderCommentCountDesc  PostOrder = "COMMENT_COUNT_DESC"
+	PostOrderLastCommentAtDesc PostOrder = "LAST_COMMENT_AT_DESC"
+)
+
+var AllPostOrder = []PostOrder{
+	PostOrderCreatedAtDesc,
+	PostOrderCreatedAtAsc,
+	PostOrderCommentCountDesc,
+	PostOrderLastCommentAtDesc,
+}
+
+func (e PostOrder) IsValid() bool {
+	switch e {
+	case PostOrderCreatedAtDesc, PostOrderCreatedAtAsc, PostOrderCommentCountDesc, PostOrderLastCommentAtDesc:
+		return true
+	}
+	return false
+}
+
+func (e PostOrder) String() string {
+	return string(e)
+}
+
+func (e *PostOrder) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = PostOrder(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid PostOrder", str)
+	}
+	return nil
+}
+
+func (e PostOrder) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
@@ -9,4 +9,6 @@ import "github.com/AEKDA/ozon_task/internal/service"
 
 type Resolver struct {
 	*service.PostService
+	*service.UserService
+	*service.ModerationService
 }
@@ -6,11 +6,79 @@ package graph
 
 import (
 	"context"
+	"strconv"
+	"time"
 
 	"github.com/AEKDA/ozon_task/internal/api/graph/model"
+	"github.com/AEKDA/ozon_task/internal/auth"
 	"github.com/AEKDA/ozon_task/internal/dataloader"
+	"github.com/AEKDA/ozon_task/internal/repository/cursor"
+	"github.com/AEKDA/ozon_task/internal/sse"
 )
 
+// Author is the resolver for the author field.
+func (r *commentResolver) Author(ctx context.Context, obj *model.Comment) (*model.User, error) {
+	return dataloader.GetUser(ctx, obj.AuthorID)
+}
+
+// ReplyCount is the resolver for the replyCount field.
+func (r *commentResolver) ReplyCount(ctx context.Context, obj *model.Comment) (int, error) {
+	return dataloader.GetReplyCount(ctx, obj.ID)
+}
+
+// Depth is the resolver for the depth field.
+func (r *commentResolver) Depth(ctx context.Context, obj *model.Comment) (int, error) {
+	return dataloader.GetDepth(ctx, obj.ID)
+}
+
+// Parent is the resolver for the parent field.
+func (r *commentResolver) Parent(ctx context.Context, obj *model.Comment) (*model.Comment, error) {
+	if obj.ReplyTo == nil {
+		return nil, nil
+	}
+	return dataloader.GetComment(ctx, *obj.ReplyTo)
+}
+
+// Replies is the resolver for the replies field.
+func (r *commentResolver) Replies(ctx context.Context, obj *model.Comment, first *int, after *string, last *int, before *string) (*model.CommentConnection, error) {
+	page, err := r.PostService.Page(first, after, last, before)
+	if err != nil {
+		return nil, err
+	}
+
+	connection, err := dataloader.GetReplies(ctx, obj.ID, page)
+	return &connection, err
+}
+
+// Revisions is the resolver for the revisions field.
+func (r *commentResolver) Revisions(ctx context.Context, obj *model.Comment, first *int, after *string) (*model.CommentRevisionConnection, error) {
+	// The revisions keep the text a deleted or hidden comment no longer
+	// shows, only moderators may read it.
+	if obj.IsDeleted || obj.IsHidden {
+		if principal := auth.ForContext(ctx); principal == nil || !principal.IsModerator() {
+			return &model.CommentRevisionConnection{}, nil
+		}
+	}
+
+	page, err := r.PostService.Page(first, after, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	connection, err := dataloader.GetCommentRevisions(ctx, obj.ID, page)
+	return &connection, err
+}
+
+// Author is the resolver for the author field.
+func (r *commentRevisionResolver) Author(ctx context.Context, obj *model.CommentRevision) (*model.User, error) {
+	return dataloader.GetUser(ctx, obj.AuthorID)
+}
+
+// RegisterUser is the resolver for the registerUser field.
+func (r *mutationResolver) RegisterUser(ctx context.Context, input model.RegisterUserInput) (*model.User, error) {
+	return r.UserService.RegisterUser(ctx, input)
+}
+
 // AddPost is the resolver for the addPost field.
 func (r *mutationResolver) AddPost(ctx context.Context, input model.AddPostInput) (*model.Post, error) {
 	return r.PostService.AddPost(ctx, input)
@@ -31,16 +99,103 @@ func (r *mutationResolver) SetCommentPremission(ctx context.Context, postID int6
 	return r.PostService.SetCommentPremission(ctx, postID, allow)
 }
 
+// UpdatePost is the resolver for the updatePost field.
+func (r *mutationResolver) UpdatePost(ctx context.Context, input model.UpdatePostInput) (*model.Post, error) {
+	return r.PostService.UpdatePost(ctx, input)
+}
+
+// DeletePost is the resolver for the deletePost field.
+func (r *mutationResolver) DeletePost(ctx context.Context, id int64) (int64, error) {
+	if err := r.PostService.DeletePost(ctx, id); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// EditComment is the resolver for the editComment field.
+func (r *mutationResolver) EditComment(ctx context.Context, input model.EditCommentInput) (*model.Comment, error) {
+	return r.PostService.EditComment(ctx, input)
+}
+
+// DeleteComment is the resolver for the deleteComment field.
+func (r *mutationResolver) DeleteComment(ctx context.Context, id int64) (*model.Comment, error) {
+	return r.PostService.DeleteComment(ctx, id)
+}
+
+// HideComment is the resolver for the hideComment field.
+func (r *mutationResolver) HideComment(ctx context.Context, id int64, hidden bool) (*model.Comment, error) {
+	return r.ModerationService.HideComment(ctx, id, hidden)
+}
+
+// PinComment is the resolver for the pinComment field.
+func (r *mutationResolver) PinComment(ctx context.Context, id int64, pinned bool) (*model.Post, error) {
+	return r.ModerationService.PinComment(ctx, id, pinned)
+}
+
+// LockPost is the resolver for the lockPost field.
+func (r *mutationResolver) LockPost(ctx context.Context, id int64, locked bool) (*model.Post, error) {
+	return r.ModerationService.LockPost(ctx, id, locked)
+}
+
+// BanAuthor is the resolver for the banAuthor field.
+func (r *mutationResolver) BanAuthor(ctx context.Context, userID int64, until time.Time) (*model.User, error) {
+	return r.ModerationService.BanAuthor(ctx, userID, until)
+}
+
+// Author is the resolver for the author field.
+func (r *postResolver) Author(ctx context.Context, obj *model.Post) (*model.User, error) {
+	return dataloader.GetUser(ctx, obj.AuthorID)
+}
+
+// PinnedComment is the resolver for the pinnedComment field.
+func (r *postResolver) PinnedComment(ctx context.Context, obj *model.Post) (*model.Comment, error) {
+	if obj.PinnedCommentID == nil {
+		return nil, nil
+	}
+
+	return dataloader.GetComment(ctx, *obj.PinnedCommentID)
+}
+
+// ViewerCount is the resolver for the viewerCount field.
+func (r *postResolver) ViewerCount(ctx context.Context, obj *model.Post) (int, error) {
+	return r.PostService.ViewerCount(obj.ID), nil
+}
+
 // Comments is the resolver for the comments field.
-func (r *postResolver) Comments(ctx context.Context, obj *model.Post, first int, after *string) (*model.CommentConnection, error) {
-	connection, err := dataloader.GetComments(ctx, obj.ID, first, after)
+func (r *postResolver) Comments(ctx context.Context, obj *model.Post, first *int, after *string, last *int, before *string, rootsOnly bool) (*model.CommentConnection, error) {
+	page, err := r.PostService.Page(first, after, last, before)
+	if err != nil {
+		return nil, err
+	}
+
+	connection, err := dataloader.GetComments(ctx, obj.ID, page, rootsOnly)
+	return &connection, err
+}
+
+// Revisions is the resolver for the revisions field.
+func (r *postResolver) Revisions(ctx context.Context, obj *model.Post, first *int, after *string) (*model.PostRevisionConnection, error) {
+	page, err := r.PostService.Page(first, after, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	connection, err := dataloader.GetPostRevisions(ctx, obj.ID, page)
 	return &connection, err
+}
 
+// Author is the resolver for the author field.
+func (r *postRevisionResolver) Author(ctx context.Context, obj *model.PostRevision) (*model.User, error) {
+	return dataloader.GetUser(ctx, obj.AuthorID)
 }
 
 // Posts is the resolver for the posts field.
-func (r *queryResolver) Posts(ctx context.Context, first int, after *string) (*model.PostConnection, error) {
-	return r.PostService.Posts(ctx, first, after)
+func (r *queryResolver) Posts(ctx context.Context, first *int, after *string, last *int, before *string, orderBy model.PostOrder, filter *model.PostFilter) (*model.PostConnection, error) {
+	page, err := r.PostService.Page(first, after, last, before)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.PostService.Posts(ctx, page, orderBy, filter)
 }
 
 // Post is the resolver for the post field.
@@ -48,24 +203,85 @@ func (r *queryResolver) Post(ctx context.Context, id int64) (*model.Post, error)
 	return r.PostService.Post(ctx, id)
 }
 
+// Search is the resolver for the search field.
+func (r *queryResolver) Search(ctx context.Context, query string, first *int, after *string) (*model.SearchConnection, error) {
+	page, err := r.PostService.Page(first, after, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.PostService.Search(ctx, query, page)
+}
+
+// Me is the resolver for the me field.
+func (r *queryResolver) Me(ctx context.Context) (*model.User, error) {
+	principal := auth.ForContext(ctx)
+	if principal == nil {
+		return nil, nil
+	}
+
+	return dataloader.GetUser(ctx, principal.UserID)
+}
+
 // CommentAdded is the resolver for the commentAdded field.
-func (r *subscriptionResolver) CommentAdded(ctx context.Context, postID int64) (<-chan *model.Comment, error) {
-	return r.PostService.SubscriptionOnPost(ctx, postID)
+func (r *subscriptionResolver) CommentAdded(ctx context.Context, postID int64, afterCursor *string) (<-chan *model.Comment, error) {
+	if afterCursor == nil {
+		// An SSE client reconnecting after a drop resumes after the last
+		// comment it received.
+		if id, err := strconv.ParseInt(sse.LastEventID(ctx), 10, 64); err == nil {
+			after := cursor.Encode(id)
+			afterCursor = &after
+		}
+	}
+
+	return r.PostService.SubscriptionOnPost(ctx, postID, afterCursor)
+}
+
+// ReplyAdded is the resolver for the replyAdded field.
+func (r *subscriptionResolver) ReplyAdded(ctx context.Context, commentID int64) (<-chan *model.Comment, error) {
+	return r.PostService.SubscriptionOnReplies(ctx, commentID)
 }
 
+// MyNotifications is the resolver for the myNotifications field.
+func (r *subscriptionResolver) MyNotifications(ctx context.Context) (<-chan *model.ReplyNotification, error) {
+	return r.PostService.SubscriptionOnNotifications(ctx)
+}
+
+// PostEvents is the resolver for the postEvents field.
+func (r *subscriptionResolver) PostEvents(ctx context.Context) (<-chan model.PostEvent, error) {
+	return r.PostService.SubscriptionOnPostEvents(ctx)
+}
+
+// ViewerCountChanged is the resolver for the viewerCountChanged field.
+func (r *subscriptionResolver) ViewerCountChanged(ctx context.Context, postID int64) (<-chan int, error) {
+	return r.PostService.SubscriptionOnViewerCount(ctx, postID)
+}
+
+// Comment returns CommentResolver implementation.
+func (r *Resolver) Comment() CommentResolver { return &commentResolver{r} }
+
+// CommentRevision returns CommentRevisionResolver implementation.
+func (r *Resolver) CommentRevision() CommentRevisionResolver { return &commentRevisionResolver{r} }
+
 // Mutation returns MutationResolver implementation.
 func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
 
 // Post returns PostResolver implementation.
 func (r *Resolver) Post() PostResolver { return &postResolver{r} }
 
+// PostRevision returns PostRevisionResolver implementation.
+func (r *Resolver) PostRevision() PostRevisionResolver { return &postRevisionResolver{r} }
+
 // Query returns QueryResolver implementation.
 func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
 
 // Subscription returns SubscriptionResolver implementation.
 func (r *Resolver) Subscription() SubscriptionResolver { return &subscriptionResolver{r} }
 
+type commentResolver struct{ *Resolver }
+type commentRevisionResolver struct{ *Resolver }
 type mutationResolver struct{ *Resolver }
 type postResolver struct{ *Resolver }
+type postRevisionResolver struct{ *Resolver }
 type queryResolver struct{ *Resolver }
 type subscriptionResolver struct{ *Resolver }
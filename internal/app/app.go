@@ -4,10 +4,14 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/99designs/gqlgen/graphql"
 	"github.com/AEKDA/ozon_task/internal/api/graph"
+	"github.com/AEKDA/ozon_task/internal/auth"
 	"github.com/AEKDA/ozon_task/internal/database/psql"
 	"github.com/AEKDA/ozon_task/internal/dataloader"
 	"github.com/AEKDA/ozon_task/internal/logger"
+	"github.com/AEKDA/ozon_task/internal/persisted"
+	"github.com/AEKDA/ozon_task/internal/pubsub"
 	"github.com/AEKDA/ozon_task/internal/repository/inmemory"
 	"github.com/AEKDA/ozon_task/internal/repository/pgrepo"
 	"github.com/AEKDA/ozon_task/internal/server"
@@ -29,30 +33,88 @@ func Run() {
 	}
 	defer log.Sync()
 
+	if err := cfg.Subscriptions.Validate(); err != nil {
+		panic(err)
+	}
+	if err := cfg.Service.Validate(); err != nil {
+		panic(err)
+	}
+	if err := cfg.Server.Validate(); err != nil {
+		panic(err)
+	}
+	switch {
+	case cfg.PersistedQueries.Cache != TypeInmemory && cfg.PersistedQueries.Cache != TypePostgres:
+		panic("invalid persisted query cache")
+	case cfg.PersistedQueries.Cache == TypePostgres && cfg.StorageType != TypePostgres:
+		panic("postgres persisted query cache requires postgres storage")
+	case cfg.PersistedQueries.Cache == TypePostgres && cfg.PersistedQueries.MaxStored < 1:
+		panic("persisted query max stored must be positive")
+	}
+
 	var repo interface {
 		service.PostRepository
 		service.CommentRepository
+		service.SearchRepository
+		service.RevisionRepository
+		service.UserRepository
+		service.ModerationRepository
+	}
+
+	var events pubsub.Bus
+
+	var persistedQueries graphql.HandlerExtension = persisted.AutomaticPersistedQuery{
+		Cache: persisted.NewMemoryCache(cfg.PersistedQueries.CacheSize),
 	}
 
 	switch cfg.StorageType {
 	case TypeInmemory:
 		repo = inmemory.NewInMemoryDB()
+		events = pubsub.NewLocalBus()
 	case TypePostgres:
 		pgconn, err := psql.NewConnection(context.Background(), cfg.Database, log)
 		if err != nil {
 			panic(err)
 		}
 		repo = pgrepo.New(pgconn, log)
+
+		bus := pubsub.NewPostgresBus(pgconn, log)
+		go bus.Listen(context.Background())
+		events = bus
+
+		if cfg.PersistedQueries.Cache == TypePostgres {
+			persistedQueries = persisted.AutomaticPersistedQuery{
+				Cache: persisted.NewPostgresCache(pgconn, log, cfg.PersistedQueries.CacheSize, cfg.PersistedQueries.MaxStored),
+			}
+		}
 	default:
 		panic("invalid storage type")
 	}
 
-	service := service.NewPostService(repo, repo)
-	resolver := &graph.Resolver{PostService: service}
+	if cfg.PersistedQueries.Allowlist != "" {
+		allowlist, err := persisted.LoadAllowlist(cfg.PersistedQueries.Allowlist)
+		if err != nil {
+			panic(err)
+		}
+		persistedQueries = allowlist
+	}
+
+	postService := service.NewPostService(repo, repo, repo, events, cfg.Subscriptions, cfg.Service)
+	go postService.ShareViewerCounts(context.Background())
+
+	resolver := &graph.Resolver{
+		PostService:       postService,
+		UserService:       service.NewUserService(repo),
+		ModerationService: service.NewModerationService(repo),
+	}
+
+	verifier, err := auth.NewVerifier(cfg.Auth)
+	if err != nil {
+		panic(err)
+	}
 
-	server := server.New(resolver, cfg.App.Host, cfg.App.Port)
+	server := server.New(resolver, dataloader.Extension{Repo: repo}, verifier, persistedQueries, cfg.Server, cfg.App.Host, cfg.App.Port)
 
-	server.Handler = dataloader.Middleware(repo, server.Handler)
+	server.Handler = auth.Middleware(verifier, server.Handler)
 	server.Handler = logger.Middleware(log, server.Handler)
 
 	log.Info("starting the server", zap.String("host", cfg.App.Host), zap.Uint32("port", cfg.App.Port))
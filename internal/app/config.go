@@ -1,15 +1,36 @@
 package app
 
-import "github.com/AEKDA/ozon_task/internal/database/psql"
+import (
+	"github.com/AEKDA/ozon_task/internal/auth"
+	"github.com/AEKDA/ozon_task/internal/database/psql"
+	"github.com/AEKDA/ozon_task/internal/pubsub"
+	"github.com/AEKDA/ozon_task/internal/server"
+	"github.com/AEKDA/ozon_task/internal/service"
+)
 
 type Config struct {
 	App struct {
 		Host string `env:"APP_HOST" envDefault:""`
 		Port uint32 `env:"APP_PORT" envDefault:"8080"`
 	}
-	Database    psql.Config
-	LogLevel    string `env:"LOG_LEVEL" envDefault:"info"`
-	StorageType string `env:"STORAGE_TYPE" envDefault:"inmemory"`
+	// PersistedQueries configures automatic persisted queries. Cache is
+	// inmemory or postgres, the latter requires the postgres storage and
+	// keeps at most MaxStored queries. With
+	// Allowlist set the server runs in strict mode: APQ is off and only the
+	// operations of the allowlist file are executed.
+	PersistedQueries struct {
+		Cache     string `env:"APQ_CACHE" envDefault:"inmemory"`
+		CacheSize int    `env:"APQ_CACHE_SIZE" envDefault:"1000"`
+		MaxStored int    `env:"APQ_MAX_STORED" envDefault:"10000"`
+		Allowlist string `env:"OPERATION_ALLOWLIST"`
+	}
+	Database      psql.Config
+	Auth          auth.Config
+	Subscriptions pubsub.Config
+	Server        server.Config
+	Service       service.Config
+	LogLevel      string `env:"LOG_LEVEL" envDefault:"info"`
+	StorageType   string `env:"STORAGE_TYPE" envDefault:"inmemory"`
 }
 
 const (
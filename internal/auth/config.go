@@ -0,0 +1,8 @@
+package auth
+
+type Config struct {
+	HMACSecret       string `env:"JWT_HMAC_SECRET"`
+	HMACSecretFile   string `env:"JWT_HMAC_SECRET_FILE"`
+	RSAPublicKey     string `env:"JWT_RSA_PUBLIC_KEY"`
+	RSAPublicKeyFile string `env:"JWT_RSA_PUBLIC_KEY_FILE"`
+}
@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrUnauthenticated is returned for operations that need a principal when
+// the request is anonymous.
+var ErrUnauthenticated = errors.New("authentication required")
+
+type ctxKey string
+
+const principalKey = ctxKey("principal")
+
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+// Principal is the authenticated user a request is made on behalf of.
+type Principal struct {
+	UserID int64
+	Role   Role
+}
+
+// IsModerator reports whether the principal may moderate content. Admins are
+// moderators too.
+func (p *Principal) IsModerator() bool {
+	return p.Role == RoleModerator || p.Role == RoleAdmin
+}
+
+func (p *Principal) IsAdmin() bool {
+	return p.Role == RoleAdmin
+}
+
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey, principal)
+}
+
+// ForContext returns the principal of the request or nil for anonymous requests.
+func ForContext(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(principalKey).(*Principal)
+	return principal
+}
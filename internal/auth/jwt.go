@@ -0,0 +1,218 @@
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+
+// Verifier validates HS256 and RS256 signed JWTs. A token is only accepted
+// with the algorithm whose key is configured, so an RS256 public key can never
+// be used as an HMAC secret.
+type Verifier struct {
+	hmacSecret []byte
+	rsaKey     *rsa.PublicKey
+	now        func() time.Time
+}
+
+func NewVerifier(cfg Config) (*Verifier, error) {
+	v := &Verifier{now: time.Now}
+
+	secret, err := readKey(cfg.HMACSecret, cfg.HMACSecretFile)
+	if err != nil {
+		return nil, fmt.Errorf("read hmac secret %w", err)
+	}
+	if len(secret) > 0 {
+		v.hmacSecret = secret
+	}
+
+	pemKey, err := readKey(cfg.RSAPublicKey, cfg.RSAPublicKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("read rsa public key %w", err)
+	}
+	if len(pemKey) > 0 {
+		v.rsaKey, err = parseRSAPublicKey(pemKey)
+		if err != nil {
+			return nil, fmt.Errorf("parse rsa public key %w", err)
+		}
+	}
+
+	return v, nil
+}
+
+// readKey returns the inline value if set and the content of the file otherwise.
+func readKey(value, file string) ([]byte, error) {
+	if value != "" {
+		return []byte(value), nil
+	}
+	if file == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimSpace(data), nil
+}
+
+func parseRSAPublicKey(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return nil, errors.New("public key is not an RSA key")
+		}
+		return rsaKey, nil
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		rsaKey, ok := cert.PublicKey.(*rsa.PublicKey)
+		if !ok {
+			return nil, errors.New("certificate key is not an RSA key")
+		}
+		return rsaKey, nil
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+}
+
+type header struct {
+	Alg string `json:"alg"`
+}
+
+type claims struct {
+	Subject   string       `json:"sub"`
+	Role      Role         `json:"role"`
+	ExpiresAt *json.Number `json:"exp"`
+	NotBefore *json.Number `json:"nbf"`
+}
+
+// Verify checks the signature and the exp/nbf claims of the token and returns
+// the principal named by its sub claim with the role from the role claim.
+func (v *Verifier) Verify(token string) (*Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed token", ErrInvalidToken)
+	}
+
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, fmt.Errorf("%w: header %v", ErrInvalidToken, err)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: signature %v", ErrInvalidToken, err)
+	}
+	if err := v.verifySignature(h.Alg, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	var c claims
+	if err := decodeSegment(parts[1], &c); err != nil {
+		return nil, fmt.Errorf("%w: claims %v", ErrInvalidToken, err)
+	}
+	if err := v.validateTime(c); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	userID, err := strconv.ParseInt(c.Subject, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: sub is not a user id", ErrInvalidToken)
+	}
+
+	role := c.Role
+	if role == "" {
+		role = RoleUser
+	}
+
+	return &Principal{UserID: userID, Role: role}, nil
+}
+
+func (v *Verifier) verifySignature(alg, signed string, signature []byte) error {
+	digest := sha256.Sum256([]byte(signed))
+
+	switch alg {
+	case "HS256":
+		if v.hmacSecret == nil {
+			return errors.New("HS256 tokens are not accepted")
+		}
+		mac := hmac.New(sha256.New, v.hmacSecret)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			return errors.New("signature mismatch")
+		}
+		return nil
+	case "RS256":
+		if v.rsaKey == nil {
+			return errors.New("RS256 tokens are not accepted")
+		}
+		if err := rsa.VerifyPKCS1v15(v.rsaKey, crypto.SHA256, digest[:], signature); err != nil {
+			return errors.New("signature mismatch")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported alg %q", alg)
+	}
+}
+
+func (v *Verifier) validateTime(c claims) error {
+	now := v.now().Unix()
+
+	if c.ExpiresAt != nil {
+		exp, err := c.ExpiresAt.Float64()
+		if err != nil {
+			return errors.New("exp is not a number")
+		}
+		if now >= int64(exp) {
+			return errors.New("token is expired")
+		}
+	}
+	if c.NotBefore != nil {
+		nbf, err := c.NotBefore.Float64()
+		if err != nil {
+			return errors.New("nbf is not a number")
+		}
+		if now < int64(nbf) {
+			return errors.New("token is not valid yet")
+		}
+	}
+
+	return nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,259 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecret = "test-secret"
+
+var testNow = time.Unix(1_700_000_000, 0)
+
+func segment(t *testing.T, v any) string {
+	t.Helper()
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("marshal segment: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func signHS256(t *testing.T, secret []byte, header, claims any) string {
+	t.Helper()
+
+	signed := segment(t, header) + "." + segment(t, claims)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, header, claims any) string {
+	t.Helper()
+
+	signed := segment(t, header) + "." + segment(t, claims)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func newRSAKey(t *testing.T) (*rsa.PrivateKey, []byte) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate rsa key: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("marshal public key: %v", err)
+	}
+	return key, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func newTestVerifier(t *testing.T, cfg Config) *Verifier {
+	t.Helper()
+
+	v, err := NewVerifier(cfg)
+	if err != nil {
+		t.Fatalf("NewVerifier: %v", err)
+	}
+	v.now = func() time.Time { return testNow }
+	return v
+}
+
+func TestVerify(t *testing.T) {
+	key, publicPEM := newRSAKey(t)
+	otherKey, _ := newRSAKey(t)
+
+	hs256 := map[string]any{"alg": "HS256", "typ": "JWT"}
+	rs256 := map[string]any{"alg": "RS256", "typ": "JWT"}
+	valid := map[string]any{"sub": "42", "role": "moderator", "exp": testNow.Add(time.Hour).Unix()}
+
+	hmacOnly := Config{HMACSecret: testSecret}
+	rsaOnly := Config{RSAPublicKey: string(publicPEM)}
+	both := Config{HMACSecret: testSecret, RSAPublicKey: string(publicPEM)}
+
+	tests := []struct {
+		name  string
+		cfg   Config
+		token string
+		want  *Principal
+	}{
+		{
+			name:  "HS256",
+			cfg:   hmacOnly,
+			token: signHS256(t, []byte(testSecret), hs256, valid),
+			want:  &Principal{UserID: 42, Role: RoleModerator},
+		},
+		{
+			name:  "RS256",
+			cfg:   rsaOnly,
+			token: signRS256(t, key, rs256, valid),
+			want:  &Principal{UserID: 42, Role: RoleModerator},
+		},
+		{
+			name:  "both keys configured",
+			cfg:   both,
+			token: signRS256(t, key, rs256, valid),
+			want:  &Principal{UserID: 42, Role: RoleModerator},
+		},
+		{
+			name:  "default role",
+			cfg:   hmacOnly,
+			token: signHS256(t, []byte(testSecret), hs256, map[string]any{"sub": "7"}),
+			want:  &Principal{UserID: 7, Role: RoleUser},
+		},
+		{
+			name:  "nbf in the past",
+			cfg:   hmacOnly,
+			token: signHS256(t, []byte(testSecret), hs256, map[string]any{"sub": "7", "nbf": testNow.Add(-time.Minute).Unix()}),
+			want:  &Principal{UserID: 7, Role: RoleUser},
+		},
+		{
+			// The public key is not secret, so a token MACed with it must
+			// not pass as HS256 when only RSA is configured.
+			name:  "public key as HMAC secret",
+			cfg:   rsaOnly,
+			token: signHS256(t, publicPEM, hs256, valid),
+		},
+		{
+			name:  "RS256 without public key",
+			cfg:   hmacOnly,
+			token: signRS256(t, key, rs256, valid),
+		},
+		{
+			name:  "alg none",
+			cfg:   both,
+			token: segment(t, map[string]any{"alg": "none"}) + "." + segment(t, valid) + ".",
+		},
+		{
+			name:  "RS256 header on HS256 signature",
+			cfg:   both,
+			token: signHS256(t, []byte(testSecret), rs256, valid),
+		},
+		{
+			name:  "wrong secret",
+			cfg:   hmacOnly,
+			token: signHS256(t, []byte("other-secret"), hs256, valid),
+		},
+		{
+			name:  "wrong rsa key",
+			cfg:   rsaOnly,
+			token: signRS256(t, otherKey, rs256, valid),
+		},
+		{
+			name:  "tampered claims",
+			cfg:   hmacOnly,
+			token: tamper(t, signHS256(t, []byte(testSecret), hs256, valid), map[string]any{"sub": "1", "role": "admin"}),
+		},
+		{
+			name:  "expired",
+			cfg:   hmacOnly,
+			token: signHS256(t, []byte(testSecret), hs256, map[string]any{"sub": "42", "exp": testNow.Unix()}),
+		},
+		{
+			name:  "not valid yet",
+			cfg:   hmacOnly,
+			token: signHS256(t, []byte(testSecret), hs256, map[string]any{"sub": "42", "nbf": testNow.Add(time.Minute).Unix()}),
+		},
+		{
+			name:  "exp is not a number",
+			cfg:   hmacOnly,
+			token: signHS256(t, []byte(testSecret), hs256, map[string]any{"sub": "42", "exp": "tomorrow"}),
+		},
+		{
+			name:  "sub is not a user id",
+			cfg:   hmacOnly,
+			token: signHS256(t, []byte(testSecret), hs256, map[string]any{"sub": "alice"}),
+		},
+		{
+			name:  "two segments",
+			cfg:   hmacOnly,
+			token: segment(t, hs256) + "." + segment(t, valid),
+		},
+		{
+			name:  "four segments",
+			cfg:   hmacOnly,
+			token: signHS256(t, []byte(testSecret), hs256, valid) + ".extra",
+		},
+		{
+			name:  "header is not base64",
+			cfg:   hmacOnly,
+			token: "!!!." + segment(t, valid) + ".sig",
+		},
+		{
+			name:  "header is not json",
+			cfg:   hmacOnly,
+			token: base64.RawURLEncoding.EncodeToString([]byte("HS256")) + "." + segment(t, valid) + ".sig",
+		},
+		{
+			name:  "signature is not base64",
+			cfg:   hmacOnly,
+			token: segment(t, hs256) + "." + segment(t, valid) + ".!!!",
+		},
+		{
+			name:  "empty",
+			cfg:   hmacOnly,
+			token: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := newTestVerifier(t, tt.cfg)
+
+			got, err := v.Verify(tt.token)
+			if tt.want == nil {
+				if !errors.Is(err, ErrInvalidToken) {
+					t.Fatalf("got principal %+v and error %v, want %v", got, err, ErrInvalidToken)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if *got != *tt.want {
+				t.Errorf("got principal %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// tamper replaces the claims of a signed token and keeps its signature.
+func tamper(t *testing.T, token string, claims any) string {
+	t.Helper()
+
+	parts := strings.Split(token, ".")
+	parts[1] = segment(t, claims)
+	return strings.Join(parts, ".")
+}
+
+func TestNewVerifierRejectsInvalidKey(t *testing.T) {
+	for name, cfg := range map[string]Config{
+		"not PEM":         {RSAPublicKey: "not a key"},
+		"private key PEM": {RSAPublicKey: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("x")}))},
+		"missing file":    {RSAPublicKeyFile: "/nonexistent/key.pem"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewVerifier(cfg); err == nil {
+				t.Error("NewVerifier accepted an invalid key")
+			}
+		})
+	}
+}
@@ -2,11 +2,14 @@ package dataloader
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"net/http"
 	"time"
 
+	"github.com/99designs/gqlgen/graphql"
+
 	"github.com/AEKDA/ozon_task/internal/api/graph/model"
+	"github.com/AEKDA/ozon_task/internal/repository/cursor"
 	"github.com/AEKDA/ozon_task/internal/service"
 	"github.com/vikstrous/dataloadgen"
 )
@@ -15,11 +18,28 @@ type ctxKey string
 
 const (
 	loadersKey = ctxKey("dataloaders")
-	paramKey   = ctxKey("param")
 )
 
-type commentReader struct {
-	db service.CommentRepository
+type Repository interface {
+	service.CommentRepository
+	service.RevisionRepository
+	service.UserRepository
+}
+
+type batchReader struct {
+	db Repository
+}
+
+// pageParam holds the pagination arguments of a connection field. Keys that
+// share the same pageParam are fetched with a single repository call.
+type pageParam struct {
+	Page      cursor.Page
+	RootsOnly bool
+}
+
+type pageKey struct {
+	ID int64
+	pageParam
 }
 
 func multiplyError(err error, n int) []error {
@@ -30,70 +50,288 @@ func multiplyError(err error, n int) []error {
 	return errs
 }
 
-func (u commentReader) getComments(ctx context.Context, postIDs []int64) ([]model.CommentConnection, []error) {
-	param, ok := ctx.Value(paramKey).(commentParam)
-	if !ok {
-		return nil, []error{fmt.Errorf("error on commentParam")}
+// loadByParam splits keys into groups with identical pagination arguments and
+// calls fetch once per group.
+func loadByParam[V any](keys []pageKey, fetch func(ids []int64, param pageParam) (map[int64]V, error)) ([]V, []error) {
+	groups := make(map[pageParam][]int)
+	for i, key := range keys {
+		groups[key.pageParam] = append(groups[key.pageParam], i)
 	}
-	comments, err := u.db.GetCommentsByPostIDs(ctx, postIDs, param.Limit, param.After)
 
+	res := make([]V, len(keys))
+	errs := make([]error, len(keys))
+	for param, positions := range groups {
+		ids := make([]int64, len(positions))
+		for i, pos := range positions {
+			ids[i] = keys[pos].ID
+		}
+
+		connections, err := fetch(ids, param)
+		for _, pos := range positions {
+			if err != nil {
+				errs[pos] = fmt.Errorf("repo error %w", err)
+				continue
+			}
+			res[pos] = connections[keys[pos].ID]
+		}
+	}
+
+	return res, errs
+}
+
+func (u batchReader) getComments(ctx context.Context, keys []pageKey) ([]model.CommentConnection, []error) {
+	return loadByParam(keys, func(postIDs []int64, param pageParam) (map[int64]model.CommentConnection, error) {
+		return u.db.GetCommentsByPostIDs(ctx, postIDs, param.Page, param.RootsOnly)
+	})
+}
+
+func (u batchReader) getReplies(ctx context.Context, keys []pageKey) ([]model.CommentConnection, []error) {
+	return loadByParam(keys, func(commentIDs []int64, param pageParam) (map[int64]model.CommentConnection, error) {
+		return u.db.GetRepliesByCommentIDs(ctx, commentIDs, param.Page)
+	})
+}
+
+func (u batchReader) getPostRevisions(ctx context.Context, keys []pageKey) ([]model.PostRevisionConnection, []error) {
+	return loadByParam(keys, func(postIDs []int64, param pageParam) (map[int64]model.PostRevisionConnection, error) {
+		return u.db.GetPostRevisions(ctx, postIDs, param.Page)
+	})
+}
+
+func (u batchReader) getCommentRevisions(ctx context.Context, keys []pageKey) ([]model.CommentRevisionConnection, []error) {
+	return loadByParam(keys, func(commentIDs []int64, param pageParam) (map[int64]model.CommentRevisionConnection, error) {
+		return u.db.GetCommentRevisions(ctx, commentIDs, param.Page)
+	})
+}
+
+func (u batchReader) getCommentsByIDs(ctx context.Context, ids []int64) ([]*model.Comment, []error) {
+	comments, err := u.db.GetCommentsByIDs(ctx, ids)
 	if err != nil {
-		return make([]model.CommentConnection, len(postIDs)), multiplyError(
+		return make([]*model.Comment, len(ids)), multiplyError(
 			fmt.Errorf("repo error %w", err),
-			len(postIDs),
+			len(ids),
 		)
 	}
 
-	res := make([]model.CommentConnection, len(postIDs))
-	for i, v := range postIDs {
-		res[i] = comments[v]
+	res := make([]*model.Comment, len(ids))
+	for i, v := range ids {
+		if comment, ok := comments[v]; ok {
+			res[i] = &comment
+		}
+	}
+
+	return res, nil
+}
+
+func (u batchReader) getUsersByIDs(ctx context.Context, ids []int64) ([]*model.User, []error) {
+	users, err := u.db.GetUsersByIDs(ctx, ids)
+	if err != nil {
+		return make([]*model.User, len(ids)), multiplyError(
+			fmt.Errorf("repo error %w", err),
+			len(ids),
+		)
+	}
+
+	res := make([]*model.User, len(ids))
+	errs := make([]error, len(ids))
+	for i, v := range ids {
+		if user, ok := users[v]; ok {
+			res[i] = &user
+		} else {
+			errs[i] = service.Errorf(service.CodeNotFound, "user %d not found", v)
+		}
+	}
+
+	return res, errs
+}
+
+func (u batchReader) countReplies(ctx context.Context, ids []int64) ([]int, []error) {
+	counts, err := u.db.CountReplies(ctx, ids)
+	if err != nil {
+		return make([]int, len(ids)), multiplyError(
+			fmt.Errorf("repo error %w", err),
+			len(ids),
+		)
+	}
+
+	res := make([]int, len(ids))
+	for i, v := range ids {
+		res[i] = counts[v]
+	}
+
+	return res, nil
+}
+
+func (u batchReader) getDepths(ctx context.Context, ids []int64) ([]int, []error) {
+	depths, err := u.db.GetCommentDepths(ctx, ids)
+	if err != nil {
+		return make([]int, len(ids)), multiplyError(
+			fmt.Errorf("repo error %w", err),
+			len(ids),
+		)
+	}
+
+	res := make([]int, len(ids))
+	for i, v := range ids {
+		res[i] = depths[v]
 	}
 
 	return res, nil
 }
 
 type Loaders struct {
-	CommentLoader *dataloadgen.Loader[int64, model.CommentConnection]
+	CommentLoader     *dataloadgen.Loader[pageKey, model.CommentConnection]
+	ReplyLoader       *dataloadgen.Loader[pageKey, model.CommentConnection]
+	CommentByIDLoader *dataloadgen.Loader[int64, *model.Comment]
+	DepthLoader       *dataloadgen.Loader[int64, int]
+	ReplyCountLoader  *dataloadgen.Loader[int64, int]
+
+	PostRevisionLoader    *dataloadgen.Loader[pageKey, model.PostRevisionConnection]
+	CommentRevisionLoader *dataloadgen.Loader[pageKey, model.CommentRevisionConnection]
+
+	UserLoader *dataloadgen.Loader[int64, *model.User]
 }
 
-func NewLoaders(repo service.CommentRepository) *Loaders {
+func NewLoaders(repo Repository) *Loaders {
 
-	ur := &commentReader{db: repo}
+	ur := &batchReader{db: repo}
 	return &Loaders{
-		CommentLoader: dataloadgen.NewLoader(ur.getComments, dataloadgen.WithWait(time.Millisecond)),
+		CommentLoader:     dataloadgen.NewLoader(ur.getComments, dataloadgen.WithWait(time.Millisecond)),
+		ReplyLoader:       dataloadgen.NewLoader(ur.getReplies, dataloadgen.WithWait(time.Millisecond)),
+		CommentByIDLoader: dataloadgen.NewLoader(ur.getCommentsByIDs, dataloadgen.WithWait(time.Millisecond)),
+		DepthLoader:       dataloadgen.NewLoader(ur.getDepths, dataloadgen.WithWait(time.Millisecond)),
+		ReplyCountLoader:  dataloadgen.NewLoader(ur.countReplies, dataloadgen.WithWait(time.Millisecond)),
+
+		PostRevisionLoader:    dataloadgen.NewLoader(ur.getPostRevisions, dataloadgen.WithWait(time.Millisecond)),
+		CommentRevisionLoader: dataloadgen.NewLoader(ur.getCommentRevisions, dataloadgen.WithWait(time.Millisecond)),
+
+		UserLoader: dataloadgen.NewLoader(ur.getUsersByIDs, dataloadgen.WithWait(time.Millisecond)),
 	}
 }
 
-func Middleware(conn service.CommentRepository, next http.Handler) http.Handler {
+// Extension gives every response its own loaders: one per query or mutation,
+// and one per event of a subscription. Loaders cache forever, so loaders
+// shared by the events of a long lived subscription would serve stale
+// replyCount, depth and the like and grow without bound.
+type Extension struct {
+	Repo Repository
+}
 
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		loader := NewLoaders(conn)
-		r = r.WithContext(context.WithValue(r.Context(), loadersKey, loader))
-		next.ServeHTTP(w, r)
-	})
+var _ interface {
+	graphql.HandlerExtension
+	graphql.ResponseInterceptor
+} = Extension{}
+
+func (e Extension) ExtensionName() string {
+	return "Dataloader"
+}
+
+func (e Extension) Validate(schema graphql.ExecutableSchema) error {
+	if e.Repo == nil {
+		return errors.New("dataloader repository can not be nil")
+	}
+	return nil
+}
+
+func (e Extension) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	return next(context.WithValue(ctx, loadersKey, NewLoaders(e.Repo)))
 }
 
 func For(ctx context.Context) *Loaders {
 	return ctx.Value(loadersKey).(*Loaders)
 }
 
-type commentParam struct {
-	Limit int
-	After *string
+func GetComments(ctx context.Context, postID int64, page cursor.Page, rootsOnly bool) (model.CommentConnection, error) {
+
+	loaders := For(ctx)
+	key := pageKey{
+		ID:        postID,
+		pageParam: pageParam{Page: page, RootsOnly: rootsOnly},
+	}
+
+	comments, err := loaders.CommentLoader.Load(ctx, key)
+	if err != nil {
+		return model.CommentConnection{}, fmt.Errorf("load from context loader %w", err)
+	}
+
+	return comments, nil
 }
 
-func GetComments(ctx context.Context, postID int64, limit int, after *string) (model.CommentConnection, error) {
+func GetReplies(ctx context.Context, commentID int64, page cursor.Page) (model.CommentConnection, error) {
 
 	loaders := For(ctx)
-	queryParam := commentParam{
-		Limit: limit,
-		After: after,
+	key := pageKey{
+		ID:        commentID,
+		pageParam: pageParam{Page: page},
 	}
 
-	comments, err := loaders.CommentLoader.Load(context.WithValue(ctx, paramKey, queryParam), postID)
+	replies, err := loaders.ReplyLoader.Load(ctx, key)
 	if err != nil {
 		return model.CommentConnection{}, fmt.Errorf("load from context loader %w", err)
 	}
 
-	return comments, nil
+	return replies, nil
+}
+
+func GetComment(ctx context.Context, id int64) (*model.Comment, error) {
+	comment, err := For(ctx).CommentByIDLoader.Load(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("load from context loader %w", err)
+	}
+
+	return comment, nil
+}
+
+func GetDepth(ctx context.Context, commentID int64) (int, error) {
+	depth, err := For(ctx).DepthLoader.Load(ctx, commentID)
+	if err != nil {
+		return 0, fmt.Errorf("load from context loader %w", err)
+	}
+
+	return depth, nil
+}
+
+func GetReplyCount(ctx context.Context, commentID int64) (int, error) {
+	count, err := For(ctx).ReplyCountLoader.Load(ctx, commentID)
+	if err != nil {
+		return 0, fmt.Errorf("load from context loader %w", err)
+	}
+
+	return count, nil
+}
+
+func GetPostRevisions(ctx context.Context, postID int64, page cursor.Page) (model.PostRevisionConnection, error) {
+	key := pageKey{
+		ID:        postID,
+		pageParam: pageParam{Page: page},
+	}
+
+	revisions, err := For(ctx).PostRevisionLoader.Load(ctx, key)
+	if err != nil {
+		return model.PostRevisionConnection{}, fmt.Errorf("load from context loader %w", err)
+	}
+
+	return revisions, nil
+}
+
+func GetCommentRevisions(ctx context.Context, commentID int64, page cursor.Page) (model.CommentRevisionConnection, error) {
+	key := pageKey{
+		ID:        commentID,
+		pageParam: pageParam{Page: page},
+	}
+
+	revisions, err := For(ctx).CommentRevisionLoader.Load(ctx, key)
+	if err != nil {
+		return model.CommentRevisionConnection{}, fmt.Errorf("load from context loader %w", err)
+	}
+
+	return revisions, nil
+}
+
+func GetUser(ctx context.Context, id int64) (*model.User, error) {
+	user, err := For(ctx).UserLoader.Load(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("load from context loader %w", err)
+	}
+
+	return user, nil
 }
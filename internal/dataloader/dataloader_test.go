@@ -0,0 +1,118 @@
+package dataloader_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/AEKDA/ozon_task/internal/api/graph"
+	"github.com/AEKDA/ozon_task/internal/api/graph/model"
+	"github.com/AEKDA/ozon_task/internal/auth"
+	"github.com/AEKDA/ozon_task/internal/dataloader"
+	"github.com/AEKDA/ozon_task/internal/pubsub"
+	"github.com/AEKDA/ozon_task/internal/repository/inmemory"
+	"github.com/AEKDA/ozon_task/internal/service"
+)
+
+type connection struct {
+	Edges []struct {
+		Node struct {
+			Content string `json:"content"`
+		} `json:"node"`
+	} `json:"edges"`
+	PageInfo struct {
+		HasNextPage bool `json:"hasNextPage"`
+	} `json:"pageInfo"`
+}
+
+func (c connection) contents() []string {
+	contents := []string{}
+	for _, edge := range c.Edges {
+		contents = append(contents, edge.Node.Content)
+	}
+	return contents
+}
+
+// TestAliasedPages loads the same post with different pagination arguments in
+// one batch: the loader key must carry the page, not only the post id.
+func TestAliasedPages(t *testing.T) {
+	ctx := context.Background()
+	db := inmemory.NewInMemoryDB()
+	posts := service.NewPostService(db, db, db, pubsub.NewLocalBus(),
+		pubsub.Config{BufferSize: 16, Overflow: pubsub.DropOldest},
+		service.Config{MaxPageSize: 100, ViewerCountInterval: time.Second})
+
+	user, err := db.AddUser(ctx, 1, model.RegisterUserInput{Name: "alice"})
+	if err != nil {
+		t.Fatalf("AddUser: %v", err)
+	}
+	ctx = auth.WithPrincipal(ctx, &auth.Principal{UserID: user.ID, Role: auth.RoleUser})
+
+	post, err := posts.AddPost(ctx, model.AddPostInput{Title: "title", Content: "content", AllowComments: true})
+	if err != nil {
+		t.Fatalf("AddPost: %v", err)
+	}
+	for _, content := range []string{"c1", "c2", "c3"} {
+		if _, err := posts.AddCommentToPost(ctx, model.AddCommentInput{PostID: post.ID, Content: content}); err != nil {
+			t.Fatalf("AddCommentToPost: %v", err)
+		}
+	}
+
+	srv := handler.New(graph.NewExecutableSchema(graph.Config{
+		Resolvers: &graph.Resolver{
+			PostService:       posts,
+			UserService:       service.NewUserService(db),
+			ModerationService: service.NewModerationService(db),
+		},
+		Directives: graph.DirectiveRoot{
+			Length: graph.LengthDirective,
+			Auth:   graph.AuthDirective,
+		},
+	}))
+	srv.AddTransport(transport.POST{})
+	srv.Use(dataloader.Extension{Repo: db})
+
+	page := `{ edges { node { content } } pageInfo { hasNextPage } }`
+	query := fmt.Sprintf(`{ post(id: %d) { two: comments(first: 2) %s ten: comments(first: 10) %s } }`, post.ID, page, page)
+	body, err := json.Marshal(map[string]string{"query": query})
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/query", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+
+	var resp struct {
+		Data struct {
+			Post struct {
+				Two connection `json:"two"`
+				Ten connection `json:"ten"`
+			} `json:"post"`
+		} `json:"data"`
+		Errors []json.RawMessage `json:"errors"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode %q: %v", rec.Body.String(), err)
+	}
+	if len(resp.Errors) > 0 {
+		t.Fatalf("errors: %s", resp.Errors)
+	}
+
+	two, ten := resp.Data.Post.Two, resp.Data.Post.Ten
+	if got, want := two.contents(), []string{"c1", "c2"}; !slices.Equal(got, want) || !two.PageInfo.HasNextPage {
+		t.Errorf("comments(first: 2): got %v next=%t, want %v next=true", got, two.PageInfo.HasNextPage, want)
+	}
+	if got, want := ten.contents(), []string{"c1", "c2", "c3"}; !slices.Equal(got, want) || ten.PageInfo.HasNextPage {
+		t.Errorf("comments(first: 10): got %v next=%t, want %v next=false", got, ten.PageInfo.HasNextPage, want)
+	}
+}
@@ -0,0 +1,83 @@
+package persisted
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/errcode"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+const errOperationNotAllowed = "OPERATION_NOT_ALLOWED"
+
+// Allowlist only lets through the operations listed in it, either sent in
+// full or referenced by their hash in the persistedQuery extension the way
+// APQ clients do. Unlike APQ, unknown queries are rejected instead of being
+// registered, so it replaces the APQ extension rather than complementing it.
+type Allowlist struct {
+	queries map[string]string
+}
+
+var _ interface {
+	graphql.OperationParameterMutator
+	graphql.HandlerExtension
+} = Allowlist{}
+
+// LoadAllowlist reads a JSON object mapping the hex SHA-256 hash of every
+// allowed query to the query, the format of persisted query manifests. The
+// hashes are checked against the queries.
+func LoadAllowlist(path string) (Allowlist, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Allowlist{}, fmt.Errorf("read allowlist %s: %w", path, err)
+	}
+
+	var queries map[string]string
+	if err := json.Unmarshal(data, &queries); err != nil {
+		return Allowlist{}, fmt.Errorf("parse allowlist %s: %w", path, err)
+	}
+
+	for hash, query := range queries {
+		if queryHash(query) != hash {
+			return Allowlist{}, fmt.Errorf("allowlist %s: hash %s does not match its query", path, hash)
+		}
+	}
+
+	return Allowlist{queries: queries}, nil
+}
+
+func (a Allowlist) ExtensionName() string {
+	return "OperationAllowlist"
+}
+
+func (a Allowlist) Validate(schema graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (a Allowlist) MutateOperationParameters(ctx context.Context, rawParams *graphql.RawParams) *gqlerror.Error {
+	hash := queryHash(rawParams.Query)
+
+	if extension, ok := rawParams.Extensions["persistedQuery"].(map[string]any); ok && rawParams.Query == "" {
+		hash, _ = extension["sha256Hash"].(string)
+	}
+
+	query, ok := a.queries[hash]
+	if !ok {
+		err := gqlerror.Errorf("operation is not in the allowlist")
+		errcode.Set(err, errOperationNotAllowed)
+		return err
+	}
+
+	rawParams.Query = query
+	return nil
+}
+
+func queryHash(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
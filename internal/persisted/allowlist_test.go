@@ -0,0 +1,116 @@
+package persisted_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/AEKDA/ozon_task/internal/persisted"
+)
+
+func hash(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
+
+func writeAllowlist(t *testing.T, queries map[string]string) string {
+	t.Helper()
+
+	data, err := json.Marshal(queries)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "allowlist.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return path
+}
+
+func TestAllowlist(t *testing.T) {
+	const allowed = "{ posts { edges { node { id } } } }"
+
+	allowlist, err := persisted.LoadAllowlist(writeAllowlist(t, map[string]string{hash(allowed): allowed}))
+	if err != nil {
+		t.Fatalf("LoadAllowlist: %v", err)
+	}
+
+	persistedQuery := func(hash string) map[string]any {
+		return map[string]any{"persistedQuery": map[string]any{"version": 1, "sha256Hash": hash}}
+	}
+
+	tests := []struct {
+		name    string
+		params  graphql.RawParams
+		allowed bool
+	}{
+		{
+			name:    "allowed query",
+			params:  graphql.RawParams{Query: allowed},
+			allowed: true,
+		},
+		{
+			name:    "hash of an allowed query",
+			params:  graphql.RawParams{Extensions: persistedQuery(hash(allowed))},
+			allowed: true,
+		},
+		{
+			name:   "unknown query",
+			params: graphql.RawParams{Query: "{ posts { edges { node { title } } } }"},
+		},
+		{
+			name:   "unknown hash",
+			params: graphql.RawParams{Extensions: persistedQuery(hash("{ me { id } }"))},
+		},
+		{
+			name: "unknown query with the hash of an allowed one",
+			params: graphql.RawParams{
+				Query:      "{ posts { edges { node { title } } } }",
+				Extensions: persistedQuery(hash(allowed)),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := tt.params
+			err := allowlist.MutateOperationParameters(context.Background(), &params)
+
+			if !tt.allowed {
+				if err == nil {
+					t.Fatalf("operation was allowed")
+				}
+				if code := err.Extensions["code"]; code != "OPERATION_NOT_ALLOWED" {
+					t.Errorf("got extensions.code %v, want OPERATION_NOT_ALLOWED", code)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("operation was rejected: %v", err)
+			}
+			if params.Query != allowed {
+				t.Errorf("got query %q, want %q", params.Query, allowed)
+			}
+		})
+	}
+}
+
+func TestLoadAllowlistErrors(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing.json")
+	if _, err := persisted.LoadAllowlist(missing); err == nil || !strings.Contains(err.Error(), missing) {
+		t.Errorf("missing file: got error %v, want one naming %s", err, missing)
+	}
+
+	mismatched := writeAllowlist(t, map[string]string{hash("{ me { id } }"): "{ me { name } }"})
+	if _, err := persisted.LoadAllowlist(mismatched); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("mismatched hash: got error %v, want a hash mismatch", err)
+	}
+}
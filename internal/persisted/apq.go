@@ -0,0 +1,57 @@
+package persisted
+
+import (
+	"context"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// Cache is a graphql.Cache whose Add does not keep the query. Queries are only
+// kept once they are handed to Store, see AutomaticPersistedQuery.
+type Cache interface {
+	graphql.Cache
+	Store(ctx context.Context, hash string, query string)
+}
+
+// AutomaticPersistedQuery is extension.AutomaticPersistedQuery for a Cache.
+// The APQ extension registers a query before it is parsed, so the query is
+// only stored once the operation passed validation and the extensions used
+// before this one, such as the complexity limit. Otherwise any client could
+// fill the cache with queries that never run.
+type AutomaticPersistedQuery struct {
+	Cache Cache
+}
+
+var _ interface {
+	graphql.OperationParameterMutator
+	graphql.OperationContextMutator
+	graphql.HandlerExtension
+} = AutomaticPersistedQuery{}
+
+func (a AutomaticPersistedQuery) ExtensionName() string {
+	return "AutomaticPersistedQuery"
+}
+
+func (a AutomaticPersistedQuery) Validate(schema graphql.ExecutableSchema) error {
+	return a.apq().Validate(schema)
+}
+
+func (a AutomaticPersistedQuery) MutateOperationParameters(ctx context.Context, params *graphql.RawParams) *gqlerror.Error {
+	return a.apq().MutateOperationParameters(ctx, params)
+}
+
+func (a AutomaticPersistedQuery) MutateOperationContext(ctx context.Context, rc *graphql.OperationContext) *gqlerror.Error {
+	// The stats are only set for requests with the persistedQuery
+	// extension, SentQuery tells a registration from a lookup.
+	if stats := extension.GetApqStats(ctx); stats != nil && stats.SentQuery {
+		a.Cache.Store(ctx, stats.Hash, rc.RawQuery)
+	}
+
+	return nil
+}
+
+func (a AutomaticPersistedQuery) apq() extension.AutomaticPersistedQuery {
+	return extension.AutomaticPersistedQuery{Cache: a.Cache}
+}
@@ -0,0 +1,209 @@
+package persisted_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AEKDA/ozon_task/internal/api/graph"
+	"github.com/AEKDA/ozon_task/internal/auth"
+	"github.com/AEKDA/ozon_task/internal/dataloader"
+	"github.com/AEKDA/ozon_task/internal/logger"
+	"github.com/AEKDA/ozon_task/internal/persisted"
+	"github.com/AEKDA/ozon_task/internal/pubsub"
+	"github.com/AEKDA/ozon_task/internal/repository/inmemory"
+	"github.com/AEKDA/ozon_task/internal/server"
+	"github.com/AEKDA/ozon_task/internal/service"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// pool connects to the database at TEST_DATABASE_URL, or returns nil if it is
+// not set.
+func pool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Log("TEST_DATABASE_URL is not set, skipping the postgres cache")
+		return nil
+	}
+
+	pool, err := pgxpool.New(context.Background(), url)
+	if err != nil {
+		t.Fatalf("connect to postgres: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	return pool
+}
+
+func newHandler(t *testing.T, cache persisted.Cache) http.Handler {
+	t.Helper()
+
+	db := inmemory.NewInMemoryDB()
+	posts := service.NewPostService(db, db, db, pubsub.NewLocalBus(),
+		pubsub.Config{BufferSize: 16, Overflow: pubsub.DropOldest},
+		service.Config{MaxPageSize: 100, ViewerCountInterval: time.Second})
+
+	verifier, err := auth.NewVerifier(auth.Config{HMACSecret: "test-secret"})
+	if err != nil {
+		t.Fatalf("NewVerifier: %v", err)
+	}
+
+	resolver := &graph.Resolver{
+		PostService:       posts,
+		UserService:       service.NewUserService(db),
+		ModerationService: service.NewModerationService(db),
+	}
+	return server.New(resolver, dataloader.Extension{Repo: db}, verifier,
+		persisted.AutomaticPersistedQuery{Cache: cache},
+		server.Config{ComplexityLimit: 1000, MaxDepth: 15}, "", 0).Handler
+}
+
+type response struct {
+	Errors []struct {
+		Message    string         `json:"message"`
+		Extensions map[string]any `json:"extensions"`
+	} `json:"errors"`
+}
+
+func (r response) codes() []any {
+	var codes []any
+	for _, err := range r.Errors {
+		codes = append(codes, err.Extensions["code"])
+	}
+	return codes
+}
+
+// send posts an APQ request for the query, with the query itself unless
+// hashOnly is set.
+func send(t *testing.T, h http.Handler, query string, hashOnly bool) response {
+	t.Helper()
+
+	params := map[string]any{
+		"extensions": map[string]any{
+			"persistedQuery": map[string]any{"version": 1, "sha256Hash": hash(query)},
+		},
+	}
+	if !hashOnly {
+		params["query"] = query
+	}
+	body, err := json.Marshal(params)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/query", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	var resp response
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode %q: %v", rec.Body.String(), err)
+	}
+	return resp
+}
+
+// TestStoresValidatedQueries registers queries through the server and looks
+// them up by their hash: only the ones that passed validation and the limits
+// are persisted.
+func TestStoresValidatedQueries(t *testing.T) {
+	caches := map[string]persisted.Cache{"memory": persisted.NewMemoryCache(100)}
+	if pool := pool(t); pool != nil {
+		caches["postgres"] = persisted.NewPostgresCache(pool, &logger.Logger{Logger: zap.NewNop()}, 100, 1000)
+	}
+
+	// Queries differ from run to run, a database keeps the ones of earlier
+	// runs.
+	run := time.Now().UnixNano()
+
+	tests := []struct {
+		name  string
+		query string
+		// code is the error of the registration, stored queries have none.
+		code any
+	}{
+		{
+			name:  "valid",
+			query: fmt.Sprintf("{ run%d: posts(first: 1) { edges { node { id } } } }", run),
+		},
+		{
+			name:  "invalid",
+			query: fmt.Sprintf("{ run%d: posts { nope } }", run),
+			code:  "GRAPHQL_VALIDATION_FAILED",
+		},
+		{
+			name:  "over the complexity limit",
+			query: fmt.Sprintf("{ run%d: posts(first: 100) { edges { node { comments(first: 100) { edges { node { id } } } } } } }", run),
+			code:  "COMPLEXITY_LIMIT_EXCEEDED",
+		},
+	}
+
+	for name, cache := range caches {
+		h := newHandler(t, cache)
+
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				var want []any
+				if tt.code != nil {
+					want = []any{tt.code}
+				}
+				if got := send(t, h, tt.query, false).codes(); !slices.Equal(got, want) {
+					t.Errorf("registration: got error codes %v, want %v", got, want)
+				}
+
+				want = nil
+				if tt.code != nil {
+					want = []any{"PERSISTED_QUERY_NOT_FOUND"}
+				}
+				if got := send(t, h, tt.query, true).codes(); !slices.Equal(got, want) {
+					t.Errorf("lookup: got error codes %v, want %v", got, want)
+				}
+			})
+		}
+	}
+}
+
+// The table keeps the maxStored most recently stored queries, whether or not
+// the older ones are still used.
+func TestPostgresCacheEviction(t *testing.T) {
+	pool := pool(t)
+	if pool == nil {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	ctx := context.Background()
+	log := &logger.Logger{Logger: zap.NewNop()}
+	cache := persisted.NewPostgresCache(pool, log, 100, 2)
+
+	run := time.Now().UnixNano()
+	var hashes []string
+	for i := 0; i < 3; i++ {
+		query := fmt.Sprintf("{ run%d: posts(first: %d) { edges { node { id } } } }", run, i+1)
+		hashes = append(hashes, hash(query))
+
+		cache.Store(ctx, hash(query), query)
+		// Using the first query does not keep it from being evicted.
+		cache.Get(ctx, hashes[0])
+		// created_at orders the queries.
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Another instance only sees what is in the table.
+	other := persisted.NewPostgresCache(pool, log, 100, 2)
+	for i, h := range hashes {
+		_, ok := other.Get(ctx, h)
+		if want := i > 0; ok != want {
+			t.Errorf("query %d stored: got %t, want %t", i, ok, want)
+		}
+	}
+}
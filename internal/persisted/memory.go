@@ -0,0 +1,30 @@
+package persisted
+
+import (
+	"context"
+
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+)
+
+// MemoryCache keeps the most recently used persisted queries of this
+// instance in memory.
+type MemoryCache struct {
+	queries *lru.LRU
+}
+
+var _ Cache = (*MemoryCache)(nil)
+
+func NewMemoryCache(size int) *MemoryCache {
+	return &MemoryCache{queries: lru.New(size)}
+}
+
+func (c *MemoryCache) Get(ctx context.Context, hash string) (any, bool) {
+	return c.queries.Get(ctx, hash)
+}
+
+// Add does nothing, the query is kept by Store once it passed validation.
+func (c *MemoryCache) Add(ctx context.Context, hash string, value any) {}
+
+func (c *MemoryCache) Store(ctx context.Context, hash string, query string) {
+	c.queries.Add(ctx, hash, query)
+}
@@ -0,0 +1,80 @@
+package persisted
+
+import (
+	"context"
+	"errors"
+
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/AEKDA/ozon_task/internal/logger"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// PostgresCache keeps persisted queries in the persisted_queries table, so
+// a query registered through one instance is known to all of them and
+// survives restarts. Recently used queries are also kept in memory to spare
+// a round trip on every request.
+type PostgresCache struct {
+	pool   *pgxpool.Pool
+	logger *logger.Logger
+	local  *lru.LRU
+	// maxStored bounds the rows of the table. Queries are evicted by the
+	// time they were stored, not by their last use, and registered again by
+	// the clients still using them.
+	maxStored int
+}
+
+var _ Cache = (*PostgresCache)(nil)
+
+func NewPostgresCache(pool *pgxpool.Pool, log *logger.Logger, size int, maxStored int) *PostgresCache {
+	return &PostgresCache{pool: pool, logger: log, local: lru.New(size), maxStored: maxStored}
+}
+
+// Get looks the query up by its hash. graphql.Cache has no way to report an
+// error, so a failed lookup is logged and treated as a miss, which makes the
+// client send the full query.
+func (c *PostgresCache) Get(ctx context.Context, hash string) (any, bool) {
+	if query, ok := c.local.Get(ctx, hash); ok {
+		return query, true
+	}
+
+	var query string
+	err := c.pool.QueryRow(ctx, "SELECT query FROM persisted_queries WHERE hash = $1", hash).Scan(&query)
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			c.logger.Error("persisted query lookup failed", zap.String("hash", hash), zap.Error(err))
+		}
+		return nil, false
+	}
+
+	c.local.Add(ctx, hash, query)
+	return query, true
+}
+
+// Add does nothing, the query is kept by Store once it passed validation.
+func (c *PostgresCache) Add(ctx context.Context, hash string, value any) {}
+
+// Store writes the query to the table, evicting the oldest queries beyond
+// maxStored. Like Get it logs failures, a query that was not stored is sent
+// again by the client.
+func (c *PostgresCache) Store(ctx context.Context, hash string, query string) {
+	c.local.Add(ctx, hash, query)
+
+	tag, err := c.pool.Exec(ctx,
+		"INSERT INTO persisted_queries (hash, query) VALUES ($1, $2) ON CONFLICT (hash) DO NOTHING",
+		hash, query)
+	if err != nil {
+		c.logger.Error("persisted query store failed", zap.String("hash", hash), zap.Error(err))
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		return
+	}
+
+	_, err = c.pool.Exec(ctx, `DELETE FROM persisted_queries WHERE hash IN (
+		SELECT hash FROM persisted_queries ORDER BY created_at DESC, hash OFFSET $1)`, c.maxStored)
+	if err != nil {
+		c.logger.Error("persisted query eviction failed", zap.Error(err))
+	}
+}
@@ -0,0 +1,62 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+)
+
+// Bus delivers the events published by any instance of the service to the
+// handlers of every instance, including the publishing one.
+type Bus interface {
+	Publish(ctx context.Context, topic string, payload []byte) error
+	// Subscribe registers a handler for the events of topic. Handlers are
+	// called one at a time in the order of events, so they must return
+	// quickly.
+	Subscribe(topic string, handler func(payload []byte))
+}
+
+type handlers struct {
+	mu     sync.RWMutex
+	topics map[string][]func(payload []byte)
+}
+
+func (h *handlers) add(topic string, handler func(payload []byte)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.topics == nil {
+		h.topics = make(map[string][]func(payload []byte))
+	}
+	h.topics[topic] = append(h.topics[topic], handler)
+}
+
+func (h *handlers) dispatch(topic string, payload []byte) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for _, handler := range h.topics[topic] {
+		handler(payload)
+	}
+}
+
+// LocalBus delivers events within a single process.
+type LocalBus struct {
+	mu       sync.Mutex
+	handlers handlers
+}
+
+func NewLocalBus() *LocalBus {
+	return &LocalBus{}
+}
+
+func (b *LocalBus) Publish(ctx context.Context, topic string, payload []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.handlers.dispatch(topic, payload)
+	return nil
+}
+
+func (b *LocalBus) Subscribe(topic string, handler func(payload []byte)) {
+	b.handlers.add(topic, handler)
+}
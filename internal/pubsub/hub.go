@@ -0,0 +1,215 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+type OverflowPolicy string
+
+const (
+	// DropOldest discards the oldest queued message of a subscriber whose
+	// queue is full to make room for the new one.
+	DropOldest OverflowPolicy = "drop-oldest"
+	// Disconnect ends the subscription of a subscriber whose queue is full.
+	Disconnect OverflowPolicy = "disconnect"
+)
+
+type Config struct {
+	BufferSize int            `env:"SUBSCRIPTION_BUFFER_SIZE" envDefault:"64"`
+	Overflow   OverflowPolicy `env:"SUBSCRIPTION_OVERFLOW" envDefault:"drop-oldest"`
+}
+
+func (c Config) Validate() error {
+	if c.BufferSize < 1 {
+		return fmt.Errorf("subscription buffer size must be positive, got %d", c.BufferSize)
+	}
+	if c.Overflow != DropOldest && c.Overflow != Disconnect {
+		return fmt.Errorf("unknown subscription overflow policy %q", c.Overflow)
+	}
+	return nil
+}
+
+// Hub fans messages published under a key out to the subscribers of that key.
+// Publish never blocks: every subscriber has its own bounded queue drained by
+// its own goroutine, and a full queue is handled by the overflow policy.
+type Hub[K comparable, V any] struct {
+	cfg Config
+
+	mu          sync.Mutex
+	subscribers map[K]map[*subscriber[V]]struct{}
+	onCount     func(key K, count int)
+}
+
+func NewHub[K comparable, V any](cfg Config) *Hub[K, V] {
+	return &Hub[K, V]{
+		cfg:         cfg,
+		subscribers: make(map[K]map[*subscriber[V]]struct{}),
+	}
+}
+
+// OnCountChange registers fn to be called with the new number of subscribers
+// of a key whenever it changes. fn is called with the hub locked, so it must
+// not block or call back into the hub. It must be set before the hub is used.
+func (h *Hub[K, V]) OnCountChange(fn func(key K, count int)) {
+	h.onCount = fn
+}
+
+// Count returns the number of subscribers of key.
+func (h *Hub[K, V]) Count(key K) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return len(h.subscribers[key])
+}
+
+type subscriber[V any] struct {
+	mu     sync.Mutex
+	queue  []V
+	closed bool
+
+	// wake has a buffer of one so that a push never waits for the pump.
+	wake chan struct{}
+	// done is closed when the subscriber is disconnected by the hub.
+	done chan struct{}
+}
+
+// Subscribe returns a channel receiving the messages published under key.
+// The subscription ends and the channel is closed when ctx is done or when
+// the subscriber is disconnected by the overflow policy.
+func (h *Hub[K, V]) Subscribe(ctx context.Context, key K) <-chan V {
+	sub := &subscriber[V]{
+		wake: make(chan struct{}, 1),
+		done: make(chan struct{}),
+	}
+
+	h.mu.Lock()
+	if h.subscribers[key] == nil {
+		h.subscribers[key] = make(map[*subscriber[V]]struct{})
+	}
+	h.subscribers[key][sub] = struct{}{}
+	h.countChanged(key)
+	h.mu.Unlock()
+
+	out := make(chan V)
+	go func() {
+		defer close(out)
+		defer h.unsubscribe(key, sub)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-sub.done:
+				return
+			case <-sub.wake:
+			}
+
+			for {
+				v, ok := sub.pop()
+				if !ok {
+					break
+				}
+
+				select {
+				case out <- v:
+				case <-ctx.Done():
+					return
+				case <-sub.done:
+					return
+				}
+			}
+		}
+	}()
+
+	return out
+}
+
+func (h *Hub[K, V]) Publish(key K, v V) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	disconnected := false
+	for sub := range h.subscribers[key] {
+		if !sub.push(v, h.cfg) {
+			delete(h.subscribers[key], sub)
+			disconnected = true
+		}
+	}
+	if len(h.subscribers[key]) == 0 {
+		delete(h.subscribers, key)
+	}
+	if disconnected {
+		h.countChanged(key)
+	}
+}
+
+func (h *Hub[K, V]) unsubscribe(key K, sub *subscriber[V]) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// A subscriber disconnected by Publish is already gone.
+	if _, ok := h.subscribers[key][sub]; !ok {
+		return
+	}
+
+	delete(h.subscribers[key], sub)
+	if len(h.subscribers[key]) == 0 {
+		delete(h.subscribers, key)
+	}
+	h.countChanged(key)
+}
+
+// countChanged must be called with h.mu held.
+func (h *Hub[K, V]) countChanged(key K) {
+	if h.onCount != nil {
+		h.onCount(key, len(h.subscribers[key]))
+	}
+}
+
+// push queues v and reports whether the subscriber is still connected.
+func (s *subscriber[V]) push(v V, cfg Config) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return false
+	}
+
+	if len(s.queue) >= cfg.BufferSize {
+		if cfg.Overflow == Disconnect {
+			s.closed = true
+			s.queue = nil
+			close(s.done)
+			return false
+		}
+
+		var zero V
+		s.queue[0] = zero
+		s.queue = s.queue[1:]
+	}
+	s.queue = append(s.queue, v)
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+
+	return true
+}
+
+func (s *subscriber[V]) pop() (V, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var zero V
+	if len(s.queue) == 0 {
+		return zero, false
+	}
+
+	v := s.queue[0]
+	s.queue[0] = zero
+	s.queue = s.queue[1:]
+	return v, true
+}
@@ -0,0 +1,206 @@
+package pubsub
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+const timeout = 5 * time.Second
+
+// drain reads ch until it is closed or, if wait is false, until nothing
+// arrives for a moment.
+func drain[V any](t *testing.T, ch <-chan V, wait bool) []V {
+	t.Helper()
+
+	idle := 50 * time.Millisecond
+	if wait {
+		idle = timeout
+	}
+
+	var got []V
+	for {
+		select {
+		case v, ok := <-ch:
+			if !ok {
+				return got
+			}
+			got = append(got, v)
+		case <-time.After(idle):
+			if wait {
+				t.Fatalf("channel was not closed after %v, received %v", timeout, got)
+			}
+			return got
+		}
+	}
+}
+
+func receive[V any](t *testing.T, ch <-chan V) V {
+	t.Helper()
+
+	select {
+	case v, ok := <-ch:
+		if !ok {
+			t.Fatal("channel closed")
+		}
+		return v
+	case <-time.After(timeout):
+		t.Fatalf("nothing received after %v", timeout)
+	}
+
+	panic("unreachable")
+}
+
+func TestPublishFansOutByKey(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	h := NewHub[int, string](Config{BufferSize: 4, Overflow: DropOldest})
+	first := h.Subscribe(ctx, 1)
+	second := h.Subscribe(ctx, 1)
+	other := h.Subscribe(ctx, 2)
+
+	h.Publish(1, "a")
+	h.Publish(1, "b")
+
+	for _, ch := range []<-chan string{first, second} {
+		if got := []string{receive(t, ch), receive(t, ch)}; !slices.Equal(got, []string{"a", "b"}) {
+			t.Errorf("received %v, want [a b]", got)
+		}
+	}
+	if got := drain(t, other, false); len(got) != 0 {
+		t.Errorf("subscriber of another key received %v", got)
+	}
+}
+
+func TestDropOldest(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const bufferSize = 2
+	h := NewHub[int, int](Config{BufferSize: bufferSize, Overflow: DropOldest})
+	ch := h.Subscribe(ctx, 1)
+
+	// Nobody reads while publishing, so apart from the one message the
+	// subscriber goroutine may be holding, only the newest messages are kept.
+	const published = 10
+	for i := 1; i <= published; i++ {
+		h.Publish(1, i)
+	}
+
+	got := drain(t, ch, false)
+	if len(got) < bufferSize || len(got) > bufferSize+1 {
+		t.Fatalf("received %v, want the last %d messages and at most one more", got, bufferSize)
+	}
+	if tail := got[len(got)-bufferSize:]; !slices.Equal(tail, []int{published - 1, published}) {
+		t.Errorf("received %v, want it to end with the newest messages", got)
+	}
+	if !slices.IsSorted(got) {
+		t.Errorf("received %v out of order", got)
+	}
+
+	// The subscriber is still connected.
+	h.Publish(1, published+1)
+	if got := receive(t, ch); got != published+1 {
+		t.Errorf("received %d, want %d", got, published+1)
+	}
+	if count := h.Count(1); count != 1 {
+		t.Errorf("Count is %d, want 1", count)
+	}
+}
+
+func TestDisconnect(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mu sync.Mutex
+	var counts []int
+
+	h := NewHub[int, int](Config{BufferSize: 1, Overflow: Disconnect})
+	h.OnCountChange(func(key int, count int) {
+		mu.Lock()
+		counts = append(counts, count)
+		mu.Unlock()
+	})
+
+	slow := h.Subscribe(ctx, 1)
+	fast := h.Subscribe(ctx, 1)
+
+	// The queue and the subscriber goroutine hold one message each, so the
+	// third one overflows the subscriber that does not read, while the one
+	// reading keeps its queue empty.
+	for i := 1; i <= 3; i++ {
+		h.Publish(1, i)
+		if got := receive(t, fast); got != i {
+			t.Fatalf("connected subscriber received %d, want %d", got, i)
+		}
+	}
+
+	if got := drain(t, slow, true); len(got) > 1 {
+		t.Errorf("disconnected subscriber received %v, want at most the message it was holding", got)
+	}
+	if count := h.Count(1); count != 1 {
+		t.Errorf("Count is %d after the disconnect, want 1", count)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !slices.Equal(counts, []int{1, 2, 1}) {
+		t.Errorf("count changes are %v, want [1 2 1]", counts)
+	}
+}
+
+func TestCountAfterCancel(t *testing.T) {
+	var mu sync.Mutex
+	var counts []int
+
+	h := NewHub[int, int](Config{BufferSize: 1, Overflow: DropOldest})
+	h.OnCountChange(func(key int, count int) {
+		mu.Lock()
+		counts = append(counts, count)
+		mu.Unlock()
+	})
+
+	firstCtx, cancelFirst := context.WithCancel(context.Background())
+	secondCtx, cancelSecond := context.WithCancel(context.Background())
+	defer cancelSecond()
+
+	first := h.Subscribe(firstCtx, 1)
+	second := h.Subscribe(secondCtx, 1)
+	if count := h.Count(1); count != 2 {
+		t.Fatalf("Count is %d, want 2", count)
+	}
+
+	// The subscription is removed before its channel is closed.
+	cancelFirst()
+	drain(t, first, true)
+	if count := h.Count(1); count != 1 {
+		t.Errorf("Count is %d after one cancellation, want 1", count)
+	}
+
+	cancelSecond()
+	drain(t, second, true)
+	if count := h.Count(1); count != 0 {
+		t.Errorf("Count is %d after both cancellations, want 0", count)
+	}
+
+	// A cancelled subscriber that was blocked on sending is removed as well.
+	ctx, cancel := context.WithCancel(context.Background())
+	blocked := h.Subscribe(ctx, 1)
+	h.Publish(1, 1)
+	h.Publish(1, 2)
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	drain(t, blocked, true)
+	if count := h.Count(1); count != 0 {
+		t.Errorf("Count is %d after cancelling a blocked subscriber, want 0", count)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !slices.Equal(counts, []int{1, 2, 1, 0, 1, 0}) {
+		t.Errorf("count changes are %v, want [1 2 1 0 1 0]", counts)
+	}
+}
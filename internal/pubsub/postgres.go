@@ -0,0 +1,100 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/AEKDA/ozon_task/internal/logger"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+const (
+	notifyChannel  = "events"
+	reconnectDelay = time.Second
+)
+
+// PostgresBus delivers events between instances sharing a database with
+// LISTEN/NOTIFY. All topics go through one channel, so a single connection
+// per instance listens for every topic.
+type PostgresBus struct {
+	pool     *pgxpool.Pool
+	logger   *logger.Logger
+	handlers handlers
+}
+
+func NewPostgresBus(pool *pgxpool.Pool, log *logger.Logger) *PostgresBus {
+	return &PostgresBus{pool: pool, logger: log}
+}
+
+type notification struct {
+	Topic   string          `json:"topic"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// Publish sends the event to every listening instance. The payload must be
+// JSON and, as a NOTIFY payload, shorter than 8000 bytes.
+func (b *PostgresBus) Publish(ctx context.Context, topic string, payload []byte) error {
+	data, err := json.Marshal(notification{Topic: topic, Payload: payload})
+	if err != nil {
+		return fmt.Errorf("marshal notification %w", err)
+	}
+
+	if _, err := b.pool.Exec(ctx, "SELECT pg_notify($1, $2)", notifyChannel, string(data)); err != nil {
+		b.logger.Error("event bus notify failed", zap.String("topic", topic), zap.Error(err))
+		return fmt.Errorf("notify failed: %v", err)
+	}
+	return nil
+}
+
+func (b *PostgresBus) Subscribe(topic string, handler func(payload []byte)) {
+	b.handlers.add(topic, handler)
+}
+
+// Listen dispatches notifications to the handlers until ctx is done. A lost
+// connection is re-established; events sent while it was down are missed.
+func (b *PostgresBus) Listen(ctx context.Context) {
+	for {
+		err := b.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		b.logger.Error("event bus connection lost", zap.Error(err))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(reconnectDelay):
+		}
+	}
+}
+
+func (b *PostgresBus) listen(ctx context.Context) error {
+	pooled, err := b.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire connection %w", err)
+	}
+	// The connection stays in LISTEN state, so it must not go back to the pool.
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+notifyChannel); err != nil {
+		return fmt.Errorf("listen failed: %v", err)
+	}
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		var msg notification
+		if err := json.Unmarshal([]byte(n.Payload), &msg); err != nil {
+			b.logger.Error("invalid event bus notification", zap.Error(err))
+			continue
+		}
+		b.handlers.dispatch(msg.Topic, msg.Payload)
+	}
+}
@@ -0,0 +1,310 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/AEKDA/ozon_task/internal/api/graph/model"
+	"github.com/AEKDA/ozon_task/internal/logger"
+	"github.com/AEKDA/ozon_task/internal/repository/cursor"
+	"github.com/AEKDA/ozon_task/internal/repository/inmemory"
+	"github.com/AEKDA/ozon_task/internal/repository/pgrepo"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// repository is the part of both backends the comment pages are built from.
+type repository interface {
+	AddUser(ctx context.Context, id int64, input model.RegisterUserInput) (*model.User, error)
+	AddPost(ctx context.Context, authorID int64, post model.AddPostInput) (*model.Post, error)
+	AddCommentToPost(ctx context.Context, authorID int64, input model.AddCommentInput) (*model.Comment, error)
+	AddReplyToComment(ctx context.Context, authorID int64, input model.AddReplyInput) (*model.Comment, error)
+	GetCommentsByPostID(ctx context.Context, postID int64, page cursor.Page, rootsOnly bool) (model.CommentConnection, error)
+	GetCommentsByPostIDs(ctx context.Context, postIDs []int64, page cursor.Page, rootsOnly bool) (map[int64]model.CommentConnection, error)
+	GetRepliesByCommentIDs(ctx context.Context, commentIDs []int64, page cursor.Page) (map[int64]model.CommentConnection, error)
+}
+
+// backends returns the in-memory backend and, when TEST_DATABASE_URL points
+// at a migrated database, the Postgres one.
+func backends(t *testing.T) map[string]repository {
+	t.Helper()
+
+	repos := map[string]repository{"inmemory": inmemory.NewInMemoryDB()}
+
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Log("TEST_DATABASE_URL is not set, skipping the postgres backend")
+		return repos
+	}
+
+	pool, err := pgxpool.New(context.Background(), url)
+	if err != nil {
+		t.Fatalf("connect to postgres: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	repos["postgres"] = pgrepo.New(pool, &logger.Logger{Logger: zap.NewNop()})
+	return repos
+}
+
+// fixture is a post with the comments
+//
+//	c1
+//	├── r1
+//	└── r2
+//	c2
+//	c3
+//	c4
+//
+// another post with the comments
+//
+//	o1
+//	└── oR
+//	o2
+//	o3
+//
+// created in the order c1, o1, c2, r1, o2, c3, oR, r2, c4, o3, and a post
+// without comments.
+type fixture struct {
+	ids     map[string]int64
+	names   map[int64]string
+	post    int64
+	other   int64
+	empty   int64
+	missing int64
+}
+
+func newFixture(t *testing.T, repo repository) fixture {
+	t.Helper()
+	ctx := context.Background()
+
+	// A database may keep the users of earlier runs.
+	id := rand.Int63n(math.MaxInt32) + 1
+	user, err := repo.AddUser(ctx, id, model.RegisterUserInput{Name: fmt.Sprintf("conformance-%d", id)})
+	if err != nil {
+		t.Fatalf("add user: %v", err)
+	}
+
+	post, err := repo.AddPost(ctx, user.ID, model.AddPostInput{Title: "post", Content: "post", AllowComments: true})
+	if err != nil {
+		t.Fatalf("add post: %v", err)
+	}
+	other, err := repo.AddPost(ctx, user.ID, model.AddPostInput{Title: "other", Content: "other", AllowComments: true})
+	if err != nil {
+		t.Fatalf("add post: %v", err)
+	}
+	empty, err := repo.AddPost(ctx, user.ID, model.AddPostInput{Title: "empty", Content: "empty", AllowComments: true})
+	if err != nil {
+		t.Fatalf("add post: %v", err)
+	}
+
+	f := fixture{
+		ids:     make(map[string]int64),
+		names:   make(map[int64]string),
+		post:    post.ID,
+		other:   other.ID,
+		empty:   empty.ID,
+		missing: empty.ID + 1_000_000,
+	}
+
+	for _, c := range []struct {
+		name, parent string
+		post         int64
+	}{
+		{"c1", "", post.ID}, {"o1", "", other.ID}, {"c2", "", post.ID}, {"r1", "c1", 0}, {"o2", "", other.ID},
+		{"c3", "", post.ID}, {"oR", "o1", 0}, {"r2", "c1", 0}, {"c4", "", post.ID}, {"o3", "", other.ID},
+	} {
+		var comment *model.Comment
+		if c.parent == "" {
+			comment, err = repo.AddCommentToPost(ctx, user.ID, model.AddCommentInput{PostID: c.post, Content: c.name})
+		} else {
+			comment, err = repo.AddReplyToComment(ctx, user.ID, model.AddReplyInput{CommentID: f.ids[c.parent], Content: c.name})
+		}
+		if err != nil {
+			t.Fatalf("add comment %s: %v", c.name, err)
+		}
+
+		f.ids[c.name] = comment.ID
+		f.names[comment.ID] = c.name
+	}
+
+	return f
+}
+
+func (f fixture) cursor(name string) string {
+	return cursor.Encode(f.ids[name])
+}
+
+type page struct {
+	names       []string
+	hasNext     bool
+	hasPrevious bool
+}
+
+func (f fixture) page(t *testing.T, connection model.CommentConnection) page {
+	t.Helper()
+
+	p := page{
+		names:       []string{},
+		hasNext:     connection.PageInfo.HasNextPage,
+		hasPrevious: connection.PageInfo.HasPreviousPage,
+	}
+	for _, edge := range connection.Edges {
+		if edge.Cursor != cursor.Encode(edge.Node.ID) {
+			t.Errorf("edge of comment %d has cursor %q", edge.Node.ID, edge.Cursor)
+		}
+		p.names = append(p.names, f.names[edge.Node.ID])
+	}
+
+	return p
+}
+
+func assertPage(t *testing.T, what string, got page, want page) {
+	t.Helper()
+
+	if !slices.Equal(got.names, want.names) || got.hasNext != want.hasNext || got.hasPrevious != want.hasPrevious {
+		t.Errorf("%s: got %v next=%t previous=%t, want %v next=%t previous=%t",
+			what, got.names, got.hasNext, got.hasPrevious, want.names, want.hasNext, want.hasPrevious)
+	}
+}
+
+func TestCommentPages(t *testing.T) {
+	tests := []struct {
+		name      string
+		page      func(f fixture) cursor.Page
+		rootsOnly bool
+		want      page
+		other     page
+	}{
+		{
+			name:  "first",
+			page:  func(f fixture) cursor.Page { return cursor.Page{Limit: 2} },
+			want:  page{names: []string{"c1", "c2"}, hasNext: true},
+			other: page{names: []string{"o1", "o2"}, hasNext: true},
+		},
+		{
+			name:  "first after",
+			page:  func(f fixture) cursor.Page { return cursor.Page{Limit: 2, After: f.cursor("c2")} },
+			want:  page{names: []string{"r1", "c3"}, hasNext: true, hasPrevious: true},
+			other: page{names: []string{"o2", "oR"}, hasNext: true, hasPrevious: true},
+		},
+		{
+			name:  "first after to the end",
+			page:  func(f fixture) cursor.Page { return cursor.Page{Limit: 5, After: f.cursor("c3")} },
+			want:  page{names: []string{"r2", "c4"}, hasPrevious: true},
+			other: page{names: []string{"oR", "o3"}, hasPrevious: true},
+		},
+		{
+			name:  "last",
+			page:  func(f fixture) cursor.Page { return cursor.Page{Limit: 2, Backward: true} },
+			want:  page{names: []string{"r2", "c4"}, hasPrevious: true},
+			other: page{names: []string{"oR", "o3"}, hasPrevious: true},
+		},
+		{
+			name:  "last before",
+			page:  func(f fixture) cursor.Page { return cursor.Page{Limit: 2, Backward: true, Before: f.cursor("r2")} },
+			want:  page{names: []string{"r1", "c3"}, hasNext: true, hasPrevious: true},
+			other: page{names: []string{"o2", "oR"}, hasNext: true, hasPrevious: true},
+		},
+		{
+			name: "between",
+			page: func(f fixture) cursor.Page {
+				return cursor.Page{Limit: 10, After: f.cursor("c1"), Before: f.cursor("r2")}
+			},
+			want:  page{names: []string{"c2", "r1", "c3"}, hasPrevious: true},
+			other: page{names: []string{"o1", "o2", "oR"}, hasPrevious: true},
+		},
+		{
+			name:      "roots only",
+			page:      func(f fixture) cursor.Page { return cursor.Page{Limit: 3} },
+			rootsOnly: true,
+			want:      page{names: []string{"c1", "c2", "c3"}, hasNext: true},
+			other:     page{names: []string{"o1", "o2", "o3"}},
+		},
+		{
+			name:  "empty page",
+			page:  func(f fixture) cursor.Page { return cursor.Page{Limit: 0} },
+			want:  page{names: []string{}, hasNext: true},
+			other: page{names: []string{}, hasNext: true},
+		},
+	}
+
+	for backend, repo := range backends(t) {
+		f := newFixture(t, repo)
+
+		for _, tt := range tests {
+			t.Run(backend+"/"+tt.name, func(t *testing.T) {
+				ctx := context.Background()
+				p := tt.page(f)
+
+				connection, err := repo.GetCommentsByPostID(ctx, f.post, p, tt.rootsOnly)
+				if err != nil {
+					t.Fatalf("GetCommentsByPostID: %v", err)
+				}
+				assertPage(t, "GetCommentsByPostID", f.page(t, connection), tt.want)
+
+				connections, err := repo.GetCommentsByPostIDs(ctx, []int64{f.post, f.other, f.empty, f.missing}, p, tt.rootsOnly)
+				if err != nil {
+					t.Fatalf("GetCommentsByPostIDs: %v", err)
+				}
+				assertPage(t, "GetCommentsByPostIDs post", f.page(t, connections[f.post]), tt.want)
+				assertPage(t, "GetCommentsByPostIDs other", f.page(t, connections[f.other]), tt.other)
+
+				// Posts without comments and missing posts get empty pages
+				// instead of failing the batch.
+				for _, id := range []int64{f.empty, f.missing} {
+					connection, ok := connections[id]
+					if !ok {
+						t.Fatalf("GetCommentsByPostIDs: no connection for post %d", id)
+					}
+					assertPage(t, fmt.Sprintf("GetCommentsByPostIDs post %d", id), f.page(t, connection),
+						page{names: []string{}, hasPrevious: p.After != "" && !p.Backward, hasNext: p.Before != "" && p.Backward})
+				}
+			})
+		}
+	}
+}
+
+func TestReplyPages(t *testing.T) {
+	for backend, repo := range backends(t) {
+		f := newFixture(t, repo)
+
+		t.Run(backend, func(t *testing.T) {
+			ctx := context.Background()
+
+			connections, err := repo.GetRepliesByCommentIDs(ctx, []int64{f.ids["c1"], f.ids["c2"], f.missing}, cursor.Page{Limit: 1})
+			if err != nil {
+				t.Fatalf("GetRepliesByCommentIDs: %v", err)
+			}
+
+			assertPage(t, "replies of c1", f.page(t, connections[f.ids["c1"]]), page{names: []string{"r1"}, hasNext: true})
+			assertPage(t, "replies of c2", f.page(t, connections[f.ids["c2"]]), page{names: []string{}})
+			assertPage(t, "replies of a missing comment", f.page(t, connections[f.missing]), page{names: []string{}})
+
+			connections, err = repo.GetRepliesByCommentIDs(ctx, []int64{f.ids["c1"]}, cursor.Page{Limit: 1, After: f.cursor("r1")})
+			if err != nil {
+				t.Fatalf("GetRepliesByCommentIDs: %v", err)
+			}
+			assertPage(t, "replies of c1 after r1", f.page(t, connections[f.ids["c1"]]), page{names: []string{"r2"}, hasPrevious: true})
+		})
+	}
+}
+
+func TestInvalidCursor(t *testing.T) {
+	for backend, repo := range backends(t) {
+		f := newFixture(t, repo)
+
+		t.Run(backend, func(t *testing.T) {
+			_, err := repo.GetCommentsByPostID(context.Background(), f.post, cursor.Page{Limit: 1, After: "not a cursor"}, false)
+			if !errors.Is(err, cursor.ErrInvalidCursor) {
+				t.Errorf("got error %v, want %v", err, cursor.ErrInvalidCursor)
+			}
+		})
+	}
+}
@@ -1,8 +1,16 @@
 package cursor
 
 import (
+	"cmp"
 	"encoding/base64"
+	"errors"
+	"fmt"
 	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
 )
 
 func Encode(n int64) string {
@@ -18,8 +26,51 @@ func Decode(cursor *string) (*int64, error) {
 
 	decoded, err := base64.StdEncoding.DecodeString(*cursor)
 	if err != nil {
-		return &val, err
+		return &val, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
 	}
 	val, err = strconv.ParseInt(string(decoded), 10, 64)
-	return &val, err
+	if err != nil {
+		return &val, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	return &val, nil
+}
+
+// Key is a position in a list sorted by Value, with ID breaking ties.
+type Key struct {
+	Value int64
+	ID    int64
+}
+
+func EncodeKey(key Key) string {
+	str := strconv.FormatInt(key.Value, 10) + ":" + strconv.FormatInt(key.ID, 10)
+	return base64.StdEncoding.EncodeToString([]byte(str))
+}
+
+func DecodeKey(cursor string) (Key, error) {
+	decoded, err := base64.StdEncoding.DecodeString(cursor)
+	if err != nil {
+		return Key{}, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+
+	value, id, found := strings.Cut(string(decoded), ":")
+	if !found {
+		return Key{}, fmt.Errorf("%w: cursor has no sort key", ErrInvalidCursor)
+	}
+
+	var key Key
+	if key.Value, err = strconv.ParseInt(value, 10, 64); err != nil {
+		return Key{}, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	if key.ID, err = strconv.ParseInt(id, 10, 64); err != nil {
+		return Key{}, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+
+	return key, nil
+}
+
+func (k Key) Compare(other Key) int {
+	if c := cmp.Compare(k.Value, other.Value); c != 0 {
+		return c
+	}
+	return cmp.Compare(k.ID, other.ID)
 }
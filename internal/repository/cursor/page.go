@@ -0,0 +1,128 @@
+package cursor
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/AEKDA/ozon_task/internal/api/graph/model"
+)
+
+const DefaultPageSize = 25
+
+var (
+	ErrInvalidPage = errors.New("invalid pagination arguments")
+)
+
+// Page is a normalized set of Relay connection arguments. A page is read
+// either forward (first/after) or backward (last/before); After and Before
+// are raw cursors, empty when not set. Page is comparable so it can be part
+// of a dataloader key.
+type Page struct {
+	Limit    int
+	Backward bool
+	After    string
+	Before   string
+}
+
+func NewPage(first *int, after *string, last *int, before *string) (Page, error) {
+	page := Page{Limit: DefaultPageSize}
+
+	switch {
+	case first != nil && last != nil:
+		return Page{}, fmt.Errorf("%w: first and last cannot be used together", ErrInvalidPage)
+	case first != nil:
+		page.Limit = *first
+	case last != nil:
+		page.Limit = *last
+		page.Backward = true
+	}
+
+	if page.Limit < 0 {
+		return Page{}, fmt.Errorf("%w: first and last must be non-negative", ErrInvalidPage)
+	}
+
+	if after != nil {
+		page.After = *after
+	}
+	if before != nil {
+		page.Before = *before
+	}
+
+	return page, nil
+}
+
+// Bounds decodes the after and before cursors; nil means unbounded.
+func (p Page) Bounds() (after *int64, before *int64, err error) {
+	if p.After != "" {
+		if after, err = Decode(&p.After); err != nil {
+			return nil, nil, err
+		}
+	}
+	if p.Before != "" {
+		if before, err = Decode(&p.Before); err != nil {
+			return nil, nil, err
+		}
+	}
+	return after, before, nil
+}
+
+// KeyBounds decodes the after and before cursors of a list sorted by Key.
+func (p Page) KeyBounds() (after *Key, before *Key, err error) {
+	if p.After != "" {
+		key, err := DecodeKey(p.After)
+		if err != nil {
+			return nil, nil, err
+		}
+		after = &key
+	}
+	if p.Before != "" {
+		key, err := DecodeKey(p.Before)
+		if err != nil {
+			return nil, nil, err
+		}
+		before = &key
+	}
+	return after, before, nil
+}
+
+// Contains reports whether id lies strictly between the page bounds.
+func (p Page) Contains(id int64, after, before *int64) bool {
+	return (after == nil || id > *after) && (before == nil || id < *before)
+}
+
+// Order returns the SQL sort direction items have to be fetched in.
+func (p Page) Order() string {
+	if p.Backward {
+		return "DESC"
+	}
+	return "ASC"
+}
+
+// Paginate cuts items, fetched in page order with up to Limit+1 elements, down
+// to the requested page and returns it in ascending order with its PageInfo.
+func Paginate[E any](items []E, page Page, cursorOf func(E) string) ([]E, model.PageInfo) {
+	hasMore := len(items) > page.Limit
+	if hasMore {
+		items = items[:page.Limit]
+	}
+	if page.Backward {
+		slices.Reverse(items)
+	}
+
+	pageInfo := model.PageInfo{
+		HasNextPage:     hasMore,
+		HasPreviousPage: page.After != "",
+	}
+	if page.Backward {
+		pageInfo.HasNextPage = page.Before != ""
+		pageInfo.HasPreviousPage = hasMore
+	}
+
+	if len(items) > 0 {
+		pageInfo.StartCursor = cursorOf(items[0])
+		pageInfo.EndCursor = cursorOf(items[len(items)-1])
+	}
+
+	return items, pageInfo
+}
@@ -2,22 +2,23 @@ package inmemory
 
 import (
 	"context"
-	"errors"
-	"fmt"
+	"slices"
 	"sync"
 	"time"
 
 	"github.com/AEKDA/ozon_task/internal/api/graph/model"
+	"github.com/AEKDA/ozon_task/internal/repository/cursor"
+	"github.com/AEKDA/ozon_task/internal/service"
 	"golang.org/x/exp/maps"
 )
 
-var (
-	ErrNotFound error = errors.New("error not found")
-)
-
 type InMemoryDB struct {
+	users            map[int64]User
 	posts            map[int64]Post
 	comments         map[int64]Comment
+	postRevisions    map[int64][]PostRevision
+	commentRevisions map[int64][]CommentRevision
+	index            searchIndex
 	mu               sync.RWMutex
 	postIDCounter    int64
 	commentIDCounter int64
@@ -25,49 +26,87 @@ type InMemoryDB struct {
 
 func NewInMemoryDB() *InMemoryDB {
 	return &InMemoryDB{
-		posts:    make(map[int64]Post),
-		comments: make(map[int64]Comment),
+		users:            make(map[int64]User),
+		posts:            make(map[int64]Post),
+		comments:         make(map[int64]Comment),
+		postRevisions:    make(map[int64][]PostRevision),
+		commentRevisions: make(map[int64][]CommentRevision),
+		index:            make(searchIndex),
 	}
 }
 
-func (db *InMemoryDB) AddPost(ctx context.Context, postInput model.AddPostInput) (*model.Post, error) {
+// checkAuthor rejects missing and banned authors. The caller holds db.mu, so
+// a ban can not land between the check and the write.
+func (db *InMemoryDB) checkAuthor(authorID int64) error {
+	user, ok := db.users[authorID]
+	if !ok {
+		return service.Errorf(service.CodeNotFound, "user %d not found", authorID)
+	}
+	if user.BannedUntil != nil && user.BannedUntil.After(time.Now()) {
+		return service.AuthorBanned(*user.BannedUntil)
+	}
+
+	return nil
+}
+
+func (db *InMemoryDB) AddPost(ctx context.Context, authorID int64, postInput model.AddPostInput) (*model.Post, error) {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
+	if err := db.checkAuthor(authorID); err != nil {
+		return nil, err
+	}
+
 	post := Post{
 		ID:            db.generatePostID(),
 		CreatedAt:     time.Now(),
 		Title:         postInput.Title,
 		Content:       postInput.Content,
-		Author:        postInput.Author,
+		AuthorID:      authorID,
 		AllowComments: postInput.AllowComments,
+		Revision:      1,
 	}
 
 	db.posts[post.ID] = post
+	db.addPostRevision(post, post.AuthorID, post.CreatedAt)
+	db.index.add(docRef{kind: postDoc, id: post.ID}, post.Title+" "+post.Content)
 
 	modelPost := post.toModel()
 	return &modelPost, nil
 }
 
-func (db *InMemoryDB) AddCommentToPost(ctx context.Context, commentInput model.AddCommentInput) (*model.Comment, error) {
+func (db *InMemoryDB) AddCommentToPost(ctx context.Context, authorID int64, commentInput model.AddCommentInput) (*model.Comment, error) {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
+	if err := db.checkAuthor(authorID); err != nil {
+		return nil, err
+	}
+
 	post, ok := db.posts[commentInput.PostID]
-	if !ok || !post.AllowComments {
-		return nil, errors.New("the post was not found or comments cannot be left under it")
+	if !ok {
+		return nil, service.Errorf(service.CodeNotFound, "post %d not found", commentInput.PostID)
+	}
+	if !post.AllowComments {
+		return nil, service.ErrCommentsDisabled
+	}
+	if post.Locked {
+		return nil, service.ErrPostLocked
 	}
 
 	comment := Comment{
 		ID:        db.generateCommentID(),
 		Content:   commentInput.Content,
-		Author:    commentInput.Author,
+		AuthorID:  authorID,
 		CreatedAt: time.Now(),
 		PostID:    post.ID,
 		ReplyTo:   nil,
+		Revision:  1,
 	}
 
 	db.comments[comment.ID] = comment
+	db.addCommentRevision(comment, comment.AuthorID, comment.CreatedAt)
+	db.index.add(docRef{kind: commentDoc, id: comment.ID}, comment.Content)
 
 	modelComment := comment.toModel()
 	return &modelComment, nil
@@ -85,69 +124,209 @@ func (db *InMemoryDB) SetCommentPremission(ctx context.Context, postID int64, al
 		return &modelPost, nil
 	}
 
-	return nil, ErrNotFound
+	return nil, service.Errorf(service.CodeNotFound, "post %d not found", postID)
 }
 
-func (db *InMemoryDB) AddReplyToComment(ctx context.Context, commentInput model.AddReplyInput) (*model.Comment, error) {
+func (db *InMemoryDB) AddReplyToComment(ctx context.Context, authorID int64, commentInput model.AddReplyInput) (*model.Comment, error) {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
+	if err := db.checkAuthor(authorID); err != nil {
+		return nil, err
+	}
+
 	comment, ok := db.comments[commentInput.CommentID]
 	if !ok {
-		return nil, fmt.Errorf("comment not found")
+		return nil, service.Errorf(service.CodeNotFound, "comment %d not found", commentInput.CommentID)
+	}
+	if comment.DeletedAt != nil {
+		return nil, service.Errorf(service.CodeValidation, "cannot reply to a deleted comment")
 	}
 
 	post, ok := db.posts[comment.PostID]
-	if !ok || !post.AllowComments {
-		return nil, fmt.Errorf("the post was not found or comments cannot be left under it")
+	if !ok {
+		return nil, service.Errorf(service.CodeNotFound, "post %d not found", comment.PostID)
+	}
+	if !post.AllowComments {
+		return nil, service.ErrCommentsDisabled
+	}
+	if post.Locked {
+		return nil, service.ErrPostLocked
 	}
 
 	reply := Comment{
 		ID:        db.generateCommentID(),
 		ReplyTo:   &commentInput.CommentID,
 		Content:   commentInput.Content,
-		Author:    commentInput.Author,
+		AuthorID:  authorID,
 		PostID:    comment.PostID,
 		CreatedAt: time.Now(),
+		Revision:  1,
 	}
 
 	db.comments[reply.ID] = reply
+	db.addCommentRevision(reply, reply.AuthorID, reply.CreatedAt)
+	db.index.add(docRef{kind: commentDoc, id: reply.ID}, reply.Content)
 
 	modelComment := reply.toModel()
 	return &modelComment, nil
 }
 
-func (db *InMemoryDB) GetPosts(ctx context.Context, first int, after *string) (*model.PostConnection, error) {
+func (db *InMemoryDB) UpdatePost(ctx context.Context, editorID int64, input model.UpdatePostInput) (*model.Post, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	post, ok := db.posts[input.ID]
+	if !ok {
+		return nil, service.Errorf(service.CodeNotFound, "post %d not found", input.ID)
+	}
+
+	ref := docRef{kind: postDoc, id: post.ID}
+	db.index.remove(ref, post.Title+" "+post.Content)
+
+	if input.Title != nil {
+		post.Title = *input.Title
+	}
+	if input.Content != nil {
+		post.Content = *input.Content
+	}
+	editedAt := time.Now()
+	post.EditedAt = &editedAt
+	post.Revision++
+
+	db.posts[post.ID] = post
+	db.addPostRevision(post, editorID, editedAt)
+	db.index.add(ref, post.Title+" "+post.Content)
+
+	modelPost := post.toModel()
+	return &modelPost, nil
+}
+
+func (db *InMemoryDB) DeletePost(ctx context.Context, postID int64) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	post, ok := db.posts[postID]
+	if !ok {
+		return service.Errorf(service.CodeNotFound, "post %d not found", postID)
+	}
+
+	for id, comment := range db.comments {
+		if comment.PostID == postID {
+			db.index.remove(docRef{kind: commentDoc, id: id}, comment.Content)
+			delete(db.comments, id)
+			delete(db.commentRevisions, id)
+		}
+	}
+
+	db.index.remove(docRef{kind: postDoc, id: postID}, post.Title+" "+post.Content)
+	delete(db.posts, postID)
+	delete(db.postRevisions, postID)
+
+	return nil
+}
+
+func (db *InMemoryDB) EditComment(ctx context.Context, editorID int64, input model.EditCommentInput) (*model.Comment, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	comment, ok := db.comments[input.ID]
+	if !ok || comment.DeletedAt != nil {
+		return nil, service.Errorf(service.CodeNotFound, "comment %d not found", input.ID)
+	}
+
+	ref := docRef{kind: commentDoc, id: comment.ID}
+	db.index.remove(ref, comment.Content)
+
+	comment.Content = input.Content
+	editedAt := time.Now()
+	comment.EditedAt = &editedAt
+	comment.Revision++
+
+	db.comments[comment.ID] = comment
+	db.addCommentRevision(comment, editorID, editedAt)
+	if !comment.Hidden {
+		db.index.add(ref, comment.Content)
+	}
+
+	modelComment := comment.toModel()
+	return &modelComment, nil
+}
+
+// DeleteComment turns the comment into a tombstone: its content is erased but
+// the comment is kept so replies still point at it.
+func (db *InMemoryDB) DeleteComment(ctx context.Context, commentID int64) (*model.Comment, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	comment, ok := db.comments[commentID]
+	if !ok {
+		return nil, service.Errorf(service.CodeNotFound, "comment %d not found", commentID)
+	}
+
+	if comment.DeletedAt == nil {
+		db.index.remove(docRef{kind: commentDoc, id: comment.ID}, comment.Content)
+
+		deletedAt := time.Now()
+		comment.Content = ""
+		comment.DeletedAt = &deletedAt
+		db.comments[comment.ID] = comment
+	}
+
+	modelComment := comment.toModel()
+	return &modelComment, nil
+}
+
+func (db *InMemoryDB) GetPosts(ctx context.Context, page cursor.Page, order model.PostOrder, filter *model.PostFilter) (*model.PostConnection, error) {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 
-	connection, err := PostsToCursorPagination(maps.Values(db.posts), first, after)
+	posts := maps.Values(db.posts)
+	if filter != nil {
+		posts = slices.DeleteFunc(posts, func(p Post) bool { return !p.matches(*filter) })
+	}
+
+	sortKey, desc := db.postSortKey(order)
+	connection, err := PostsToCursorPagination(posts, page, sortKey, desc)
 
 	return &connection, err
 }
 
-func (db *InMemoryDB) GetCommentsByPostIDs(ctx context.Context, postIDs []int64, first int, after *string) (map[int64]model.CommentConnection, error) {
+// postSortKey returns the sort key of a post for the given order and whether
+// the feed is sorted in descending order. Posts without comments are ordered
+// by their creation time in LAST_COMMENT_AT_DESC.
+// postSortKey must be called with db.mu held.
+func (db *InMemoryDB) postSortKey(order model.PostOrder) (func(Post) int64, bool) {
+	switch order {
+	case model.PostOrderCreatedAtDesc:
+		return func(p Post) int64 { return p.CreatedAt.UnixMicro() }, true
+	case model.PostOrderCommentCountDesc:
+		counts := make(map[int64]int64)
+		for _, v := range db.comments {
+			counts[v.PostID]++
+		}
+		return func(p Post) int64 { return counts[p.ID] }, true
+	case model.PostOrderLastCommentAtDesc:
+		lastComment := make(map[int64]int64)
+		for _, v := range db.comments {
+			lastComment[v.PostID] = max(lastComment[v.PostID], v.CreatedAt.UnixMicro())
+		}
+		return func(p Post) int64 { return max(lastComment[p.ID], p.CreatedAt.UnixMicro()) }, true
+	default:
+		return func(p Post) int64 { return p.CreatedAt.UnixMicro() }, false
+	}
+}
+
+func (db *InMemoryDB) GetCommentsByPostIDs(ctx context.Context, postIDs []int64, page cursor.Page, rootsOnly bool) (map[int64]model.CommentConnection, error) {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 
 	connections := make(map[int64]model.CommentConnection)
 
 	for _, postID := range postIDs {
-		_, ok := db.posts[postID]
-		if !ok {
-			return nil, fmt.Errorf("%w post id: %d", ErrNotFound, postID)
-		}
-
-		comments := []Comment{}
-		for _, v := range db.comments {
-			if v.PostID == postID {
-				comments = append(comments, v)
-			}
-		}
-
-		connection, err := commentsToCursorPagination(comments, first, after)
+		connection, err := commentsToCursorPagination(db.postComments(postID, rootsOnly), page)
 		if err != nil {
-			return nil, fmt.Errorf("cursor is invalid")
+			return nil, err
 		}
 
 		connections[postID] = connection
@@ -155,25 +334,104 @@ func (db *InMemoryDB) GetCommentsByPostIDs(ctx context.Context, postIDs []int64,
 
 	return connections, nil
 }
-func (db *InMemoryDB) GetCommentsByPostID(ctx context.Context, postID int64, first int, after *string) (model.CommentConnection, error) {
+func (db *InMemoryDB) GetCommentsByPostID(ctx context.Context, postID int64, page cursor.Page, rootsOnly bool) (model.CommentConnection, error) {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 
-	_, ok := db.posts[postID]
-	if !ok {
-		return model.CommentConnection{}, ErrNotFound
+	return commentsToCursorPagination(db.postComments(postID, rootsOnly), page)
+}
+
+func (db *InMemoryDB) CountReplies(ctx context.Context, commentIDs []int64) (map[int64]int, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	counts := make(map[int64]int, len(commentIDs))
+	for _, id := range commentIDs {
+		counts[id] = 0
+	}
+	for _, v := range db.comments {
+		if v.ReplyTo == nil {
+			continue
+		}
+		if _, ok := counts[*v.ReplyTo]; ok {
+			counts[*v.ReplyTo]++
+		}
 	}
 
+	return counts, nil
+}
+
+// postComments must be called with db.mu held.
+func (db *InMemoryDB) postComments(postID int64, rootsOnly bool) []Comment {
 	comments := []Comment{}
 	for _, v := range db.comments {
-		if v.PostID == postID {
-			comments = append(comments, v)
+		if v.PostID != postID || (rootsOnly && v.ReplyTo != nil) {
+			continue
+		}
+		comments = append(comments, v)
+	}
+
+	return comments
+}
+
+func (db *InMemoryDB) GetCommentsByIDs(ctx context.Context, ids []int64) (map[int64]model.Comment, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	comments := make(map[int64]model.Comment, len(ids))
+	for _, id := range ids {
+		if comment, ok := db.comments[id]; ok {
+			comments[id] = comment.toModel()
+		}
+	}
+
+	return comments, nil
+}
+
+func (db *InMemoryDB) GetRepliesByCommentIDs(ctx context.Context, commentIDs []int64, page cursor.Page) (map[int64]model.CommentConnection, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	replies := make(map[int64][]Comment, len(commentIDs))
+	for _, v := range db.comments {
+		if v.ReplyTo != nil {
+			replies[*v.ReplyTo] = append(replies[*v.ReplyTo], v)
+		}
+	}
+
+	connections := make(map[int64]model.CommentConnection, len(commentIDs))
+	for _, commentID := range commentIDs {
+		connection, err := commentsToCursorPagination(replies[commentID], page)
+		if err != nil {
+			return nil, err
 		}
+
+		connections[commentID] = connection
 	}
 
-	connection, err := commentsToCursorPagination(comments, first, after)
+	return connections, nil
+}
 
-	return connection, err
+func (db *InMemoryDB) GetCommentDepths(ctx context.Context, ids []int64) (map[int64]int, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	depths := make(map[int64]int, len(ids))
+	for _, id := range ids {
+		comment, ok := db.comments[id]
+		if !ok {
+			continue
+		}
+
+		depth := 0
+		for comment.ReplyTo != nil {
+			comment = db.comments[*comment.ReplyTo]
+			depth++
+		}
+		depths[id] = depth
+	}
+
+	return depths, nil
 }
 
 func (db *InMemoryDB) GetPostByID(ctx context.Context, id int64) (*model.Post, error) {
@@ -182,13 +440,69 @@ func (db *InMemoryDB) GetPostByID(ctx context.Context, id int64) (*model.Post, e
 
 	post, ok := db.posts[id]
 	if !ok {
-		return nil, ErrNotFound
+		return nil, service.Errorf(service.CodeNotFound, "post %d not found", id)
 	}
 
 	modelPost := post.toModel()
 	return &modelPost, nil
 }
 
+func (db *InMemoryDB) GetPostRevisions(ctx context.Context, postIDs []int64, page cursor.Page) (map[int64]model.PostRevisionConnection, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	connections := make(map[int64]model.PostRevisionConnection, len(postIDs))
+	for _, postID := range postIDs {
+		connection, err := postRevisionsToCursorPagination(db.postRevisions[postID], page)
+		if err != nil {
+			return nil, err
+		}
+
+		connections[postID] = connection
+	}
+
+	return connections, nil
+}
+
+func (db *InMemoryDB) GetCommentRevisions(ctx context.Context, commentIDs []int64, page cursor.Page) (map[int64]model.CommentRevisionConnection, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	connections := make(map[int64]model.CommentRevisionConnection, len(commentIDs))
+	for _, commentID := range commentIDs {
+		connection, err := commentRevisionsToCursorPagination(db.commentRevisions[commentID], page)
+		if err != nil {
+			return nil, err
+		}
+
+		connections[commentID] = connection
+	}
+
+	return connections, nil
+}
+
+// addPostRevision records the current title and content of the post as
+// written by editorID. It must be called with db.mu held.
+func (db *InMemoryDB) addPostRevision(post Post, editorID int64, at time.Time) {
+	db.postRevisions[post.ID] = append(db.postRevisions[post.ID], PostRevision{
+		Revision:  post.Revision,
+		Title:     post.Title,
+		Content:   post.Content,
+		AuthorID:  editorID,
+		CreatedAt: at,
+	})
+}
+
+// addCommentRevision is addPostRevision for comments.
+func (db *InMemoryDB) addCommentRevision(comment Comment, editorID int64, at time.Time) {
+	db.commentRevisions[comment.ID] = append(db.commentRevisions[comment.ID], CommentRevision{
+		Revision:  comment.Revision,
+		Content:   comment.Content,
+		AuthorID:  editorID,
+		CreatedAt: at,
+	})
+}
+
 func (db *InMemoryDB) generatePostID() int64 {
 	db.postIDCounter++
 	return db.postIDCounter
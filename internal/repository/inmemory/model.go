@@ -12,30 +12,60 @@ type Post struct {
 	ID            int64
 	Title         string
 	Content       string
-	Author        string
+	AuthorID      int64
 	CreatedAt     time.Time
+	EditedAt      *time.Time
 	AllowComments bool
+	Locked        bool
+	PinnedComment *int64
+	Revision      int
 }
 
 type Comment struct {
 	ID        int64
 	PostID    int64
 	Content   string
-	Author    string
+	AuthorID  int64
 	CreatedAt time.Time
+	EditedAt  *time.Time
+	DeletedAt *time.Time
+	Hidden    bool
 	ReplyTo   *int64
+	Revision  int
 }
 
-func commentsToCursorPagination(comments []Comment, first int, after *string) (model.CommentConnection, error) {
+type User struct {
+	ID          int64
+	Name        string
+	CreatedAt   time.Time
+	BannedUntil *time.Time
+}
+
+type PostRevision struct {
+	Revision  int
+	Title     string
+	Content   string
+	AuthorID  int64
+	CreatedAt time.Time
+}
+
+type CommentRevision struct {
+	Revision  int
+	Content   string
+	AuthorID  int64
+	CreatedAt time.Time
+}
+
+func commentsToCursorPagination(comments []Comment, page cursor.Page) (model.CommentConnection, error) {
 
 	var filteredComments []model.CommentEdge
 
-	afterID, err := cursor.Decode(after)
+	afterID, beforeID, err := page.Bounds()
 	if err != nil {
 		return model.CommentConnection{}, err
 	}
 	for _, comment := range comments {
-		if afterID != nil && comment.ID <= *afterID {
+		if !page.Contains(comment.ID, afterID, beforeID) {
 			continue
 		}
 		filteredComments = append(filteredComments, model.CommentEdge{
@@ -45,82 +75,205 @@ func commentsToCursorPagination(comments []Comment, first int, after *string) (m
 	}
 
 	slices.SortFunc(filteredComments, func(a model.CommentEdge, b model.CommentEdge) int { return int(a.Node.ID - b.Node.ID) })
-
-	pageInfo := model.PageInfo{
-		HasNextPage: len(filteredComments) > first,
-	}
-	if len(filteredComments) > first {
-		filteredComments = filteredComments[:first]
+	if page.Backward {
+		slices.Reverse(filteredComments)
 	}
 
-	if len(filteredComments) > 0 {
-		pageInfo.StartCursor = filteredComments[0].Cursor
-		pageInfo.EndCursor = filteredComments[len(filteredComments)-1].Cursor
-	}
+	edges, pageInfo := cursor.Paginate(filteredComments, page, func(e model.CommentEdge) string { return e.Cursor })
 
 	return model.CommentConnection{
-		Edges:    filteredComments,
+		Edges:    edges,
 		PageInfo: pageInfo,
 	}, nil
 }
 
-func PostsToCursorPagination(posts []Post, first int, after *string) (model.PostConnection, error) {
+func PostsToCursorPagination(posts []Post, page cursor.Page, sortKey func(Post) int64, desc bool) (model.PostConnection, error) {
 
-	var filteredPosts []model.PostEdge
+	type keyedPost struct {
+		key  cursor.Key
+		post Post
+	}
+	var filteredPosts []keyedPost
 
-	afterID, err := cursor.Decode(after)
+	afterKey, beforeKey, err := page.KeyBounds()
 	if err != nil {
 		return model.PostConnection{}, err
 	}
 
+	compare := func(a cursor.Key, b cursor.Key) int {
+		if desc {
+			return b.Compare(a)
+		}
+		return a.Compare(b)
+	}
+
 	for _, post := range posts {
-		if afterID != nil && post.ID <= *afterID {
+		key := cursor.Key{Value: sortKey(post), ID: post.ID}
+		if afterKey != nil && compare(key, *afterKey) <= 0 {
+			continue
+		}
+		if beforeKey != nil && compare(key, *beforeKey) >= 0 {
+			continue
+		}
+		filteredPosts = append(filteredPosts, keyedPost{key: key, post: post})
+	}
+
+	slices.SortFunc(filteredPosts, func(a keyedPost, b keyedPost) int { return compare(a.key, b.key) })
+	if page.Backward {
+		slices.Reverse(filteredPosts)
+	}
+
+	edges := make([]model.PostEdge, 0, len(filteredPosts))
+	for _, v := range filteredPosts {
+		edges = append(edges, model.PostEdge{
+			Node:   v.post.toModel(),
+			Cursor: cursor.EncodeKey(v.key),
+		})
+	}
+
+	edges, pageInfo := cursor.Paginate(edges, page, func(e model.PostEdge) string { return e.Cursor })
+
+	return model.PostConnection{
+		Edges:    edges,
+		PageInfo: pageInfo,
+	}, nil
+}
+
+func postRevisionsToCursorPagination(revisions []PostRevision, page cursor.Page) (model.PostRevisionConnection, error) {
+
+	var filteredRevisions []model.PostRevisionEdge
+
+	afterRev, beforeRev, err := page.Bounds()
+	if err != nil {
+		return model.PostRevisionConnection{}, err
+	}
+	for _, revision := range revisions {
+		if !page.Contains(int64(revision.Revision), afterRev, beforeRev) {
 			continue
 		}
-		filteredPosts = append(filteredPosts, model.PostEdge{
-			Node:   post.toModel(),
-			Cursor: cursor.Encode(post.ID),
+		filteredRevisions = append(filteredRevisions, model.PostRevisionEdge{
+			Node:   revision.toModel(),
+			Cursor: cursor.Encode(int64(revision.Revision)),
 		})
 	}
 
-	slices.SortFunc(filteredPosts, func(a model.PostEdge, b model.PostEdge) int { return int(a.Node.ID - b.Node.ID) })
+	if page.Backward {
+		slices.Reverse(filteredRevisions)
+	}
 
-	pageInfo := model.PageInfo{
-		HasNextPage: len(filteredPosts) > first,
+	edges, pageInfo := cursor.Paginate(filteredRevisions, page, func(e model.PostRevisionEdge) string { return e.Cursor })
+
+	return model.PostRevisionConnection{
+		Edges:    edges,
+		PageInfo: pageInfo,
+	}, nil
+}
+
+func commentRevisionsToCursorPagination(revisions []CommentRevision, page cursor.Page) (model.CommentRevisionConnection, error) {
+
+	var filteredRevisions []model.CommentRevisionEdge
+
+	afterRev, beforeRev, err := page.Bounds()
+	if err != nil {
+		return model.CommentRevisionConnection{}, err
 	}
-	if len(filteredPosts) > first {
-		filteredPosts = filteredPosts[:first]
+	for _, revision := range revisions {
+		if !page.Contains(int64(revision.Revision), afterRev, beforeRev) {
+			continue
+		}
+		filteredRevisions = append(filteredRevisions, model.CommentRevisionEdge{
+			Node:   revision.toModel(),
+			Cursor: cursor.Encode(int64(revision.Revision)),
+		})
 	}
 
-	if len(filteredPosts) > 0 {
-		pageInfo.StartCursor = filteredPosts[0].Cursor
-		pageInfo.EndCursor = filteredPosts[len(filteredPosts)-1].Cursor
+	if page.Backward {
+		slices.Reverse(filteredRevisions)
 	}
 
-	return model.PostConnection{
-		Edges:    filteredPosts,
+	edges, pageInfo := cursor.Paginate(filteredRevisions, page, func(e model.CommentRevisionEdge) string { return e.Cursor })
+
+	return model.CommentRevisionConnection{
+		Edges:    edges,
 		PageInfo: pageInfo,
 	}, nil
 }
 
+// matches reports whether the post satisfies every set field of the filter.
+// The creation time range is half-open: [createdAfter, createdBefore).
+func (p Post) matches(filter model.PostFilter) bool {
+	if filter.AuthorID != nil && p.AuthorID != *filter.AuthorID {
+		return false
+	}
+	if filter.CreatedAfter != nil && p.CreatedAt.Before(*filter.CreatedAfter) {
+		return false
+	}
+	if filter.CreatedBefore != nil && !p.CreatedAt.Before(*filter.CreatedBefore) {
+		return false
+	}
+	if filter.AllowComments != nil && p.AllowComments != *filter.AllowComments {
+		return false
+	}
+	return true
+}
+
+func (u User) toModel() model.User {
+	return model.User{
+		ID:          u.ID,
+		Name:        u.Name,
+		CreatedAt:   u.CreatedAt,
+		BannedUntil: u.BannedUntil,
+	}
+}
+
 func (c Comment) toModel() model.Comment {
-	return model.Comment{
+	comment := model.Comment{
 		ID:        c.ID,
+		PostID:    c.PostID,
 		Content:   c.Content,
-		Author:    c.Author,
+		AuthorID:  c.AuthorID,
 		CreatedAt: c.CreatedAt,
+		EditedAt:  c.EditedAt,
+		IsDeleted: c.DeletedAt != nil,
+		IsHidden:  c.Hidden,
 		ReplyTo:   c.ReplyTo,
 	}
+	if c.Hidden {
+		comment.Content = ""
+	}
+	return comment
 }
 
 func (c Post) toModel() model.Post {
 	return model.Post{
-		ID:            c.ID,
-		Content:       c.Content,
-		Author:        c.Author,
-		CreatedAt:     c.CreatedAt,
-		Title:         c.Title,
-		AllowComments: c.AllowComments,
-		Comments:      model.CommentConnection{},
+		ID:              c.ID,
+		Content:         c.Content,
+		AuthorID:        c.AuthorID,
+		CreatedAt:       c.CreatedAt,
+		EditedAt:        c.EditedAt,
+		Title:           c.Title,
+		AllowComments:   c.AllowComments,
+		Locked:          c.Locked,
+		PinnedCommentID: c.PinnedComment,
+		Comments:        model.CommentConnection{},
+	}
+}
+
+func (r PostRevision) toModel() model.PostRevision {
+	return model.PostRevision{
+		Revision:  r.Revision,
+		Title:     r.Title,
+		Content:   r.Content,
+		AuthorID:  r.AuthorID,
+		CreatedAt: r.CreatedAt,
+	}
+}
+
+func (r CommentRevision) toModel() model.CommentRevision {
+	return model.CommentRevision{
+		Revision:  r.Revision,
+		Content:   r.Content,
+		AuthorID:  r.AuthorID,
+		CreatedAt: r.CreatedAt,
 	}
 }
@@ -0,0 +1,92 @@
+package inmemory
+
+import (
+	"context"
+	"time"
+
+	"github.com/AEKDA/ozon_task/internal/api/graph/model"
+	"github.com/AEKDA/ozon_task/internal/service"
+)
+
+func (db *InMemoryDB) HideComment(ctx context.Context, commentID int64, hidden bool) (*model.Comment, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	comment, ok := db.comments[commentID]
+	if !ok {
+		return nil, service.Errorf(service.CodeNotFound, "comment %d not found", commentID)
+	}
+
+	if comment.Hidden != hidden {
+		ref := docRef{kind: commentDoc, id: comment.ID}
+		if hidden {
+			db.index.remove(ref, comment.Content)
+		} else {
+			db.index.add(ref, comment.Content)
+		}
+
+		comment.Hidden = hidden
+		db.comments[comment.ID] = comment
+	}
+
+	modelComment := comment.toModel()
+	return &modelComment, nil
+}
+
+func (db *InMemoryDB) PinComment(ctx context.Context, commentID int64, pinned bool) (*model.Post, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	comment, ok := db.comments[commentID]
+	if !ok {
+		return nil, service.Errorf(service.CodeNotFound, "comment %d not found", commentID)
+	}
+
+	post, ok := db.posts[comment.PostID]
+	if !ok {
+		return nil, service.Errorf(service.CodeNotFound, "post %d not found", comment.PostID)
+	}
+
+	switch {
+	case pinned:
+		post.PinnedComment = &comment.ID
+	case post.PinnedComment != nil && *post.PinnedComment == comment.ID:
+		post.PinnedComment = nil
+	}
+	db.posts[post.ID] = post
+
+	modelPost := post.toModel()
+	return &modelPost, nil
+}
+
+func (db *InMemoryDB) LockPost(ctx context.Context, postID int64, locked bool) (*model.Post, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	post, ok := db.posts[postID]
+	if !ok {
+		return nil, service.Errorf(service.CodeNotFound, "post %d not found", postID)
+	}
+
+	post.Locked = locked
+	db.posts[post.ID] = post
+
+	modelPost := post.toModel()
+	return &modelPost, nil
+}
+
+func (db *InMemoryDB) BanUser(ctx context.Context, userID int64, until time.Time) (*model.User, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	user, ok := db.users[userID]
+	if !ok {
+		return nil, service.Errorf(service.CodeNotFound, "user %d not found", userID)
+	}
+
+	user.BannedUntil = &until
+	db.users[user.ID] = user
+
+	modelUser := user.toModel()
+	return &modelUser, nil
+}
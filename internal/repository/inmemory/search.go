@@ -0,0 +1,177 @@
+package inmemory
+
+import (
+	"cmp"
+	"context"
+	"html"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/AEKDA/ozon_task/internal/api/graph/model"
+	"github.com/AEKDA/ozon_task/internal/repository/cursor"
+	"golang.org/x/exp/maps"
+)
+
+const (
+	snippetWords   = 35
+	snippetContext = 5
+)
+
+// Kinds are named after the Postgres backend so both order hits alike.
+const (
+	commentDoc = "comment"
+	postDoc    = "post"
+)
+
+type docRef struct {
+	kind string
+	id   int64
+}
+
+type searchHit struct {
+	ref  docRef
+	rank int
+}
+
+// searchIndex is an inverted index from a lowercased word to the documents
+// containing it and the number of its occurrences in each of them.
+type searchIndex map[string]map[docRef]int
+
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func (idx searchIndex) add(ref docRef, text string) {
+	for _, word := range tokenize(text) {
+		docs, ok := idx[word]
+		if !ok {
+			docs = make(map[docRef]int)
+			idx[word] = docs
+		}
+		docs[ref]++
+	}
+}
+
+func (idx searchIndex) remove(ref docRef, text string) {
+	for _, word := range tokenize(text) {
+		docs := idx[word]
+		delete(docs, ref)
+		if len(docs) == 0 {
+			delete(idx, word)
+		}
+	}
+}
+
+// search returns the documents containing every word of the query, ranked by
+// the number of occurrences of the query words.
+func (idx searchIndex) search(query string) []searchHit {
+	words := tokenize(query)
+	if len(words) == 0 {
+		return nil
+	}
+
+	ranks := maps.Clone(idx[words[0]])
+	for _, word := range words[1:] {
+		docs := idx[word]
+		for ref := range ranks {
+			count, ok := docs[ref]
+			if !ok {
+				delete(ranks, ref)
+				continue
+			}
+			ranks[ref] += count
+		}
+	}
+
+	hits := make([]searchHit, 0, len(ranks))
+	for ref, rank := range ranks {
+		hits = append(hits, searchHit{ref: ref, rank: rank})
+	}
+
+	slices.SortFunc(hits, func(a searchHit, b searchHit) int {
+		if c := cmp.Compare(b.rank, a.rank); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(a.ref.kind, b.ref.kind); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ref.id, b.ref.id)
+	})
+
+	return hits
+}
+
+// highlight wraps the words of text matching the query in <b></b>, the way
+// ts_headline does by default, and cuts the text down to the first match.
+// The words are HTML escaped, so the snippet is safe to render as HTML.
+func highlight(text string, query string) string {
+	terms := make(map[string]struct{})
+	for _, word := range tokenize(query) {
+		terms[word] = struct{}{}
+	}
+
+	words := strings.Fields(text)
+	firstMatch := -1
+	for i, word := range words {
+		words[i] = html.EscapeString(word)
+		for _, token := range tokenize(word) {
+			if _, ok := terms[token]; ok {
+				words[i] = "<b>" + words[i] + "</b>"
+				if firstMatch < 0 {
+					firstMatch = i
+				}
+				break
+			}
+		}
+	}
+
+	start := max(firstMatch-snippetContext, 0)
+	end := min(start+snippetWords, len(words))
+
+	return strings.Join(words[start:end], " ")
+}
+
+func (db *InMemoryDB) Search(ctx context.Context, query string, page cursor.Page) (*model.SearchConnection, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	after, _, err := page.Bounds()
+	if err != nil {
+		return nil, err
+	}
+
+	hits := db.index.search(query)
+
+	offset := 0
+	if after != nil {
+		offset = min(int(max(*after, 0)), len(hits))
+	}
+
+	edges := make([]model.SearchEdge, 0, len(hits)-offset)
+	for i, hit := range hits[offset:] {
+		edge := model.SearchEdge{Cursor: cursor.Encode(int64(offset + i + 1))}
+
+		switch hit.ref.kind {
+		case postDoc:
+			post := db.posts[hit.ref.id]
+			edge.Node = post.toModel()
+			edge.Snippet = highlight(post.Title+" "+post.Content, query)
+		case commentDoc:
+			comment := db.comments[hit.ref.id]
+			edge.Node = comment.toModel()
+			edge.Snippet = highlight(comment.Content, query)
+		}
+
+		edges = append(edges, edge)
+	}
+
+	edges, pageInfo := cursor.Paginate(edges, page, func(e model.SearchEdge) string { return e.Cursor })
+
+	return &model.SearchConnection{
+		Edges:    edges,
+		PageInfo: pageInfo,
+	}, nil
+}
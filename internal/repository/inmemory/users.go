@@ -0,0 +1,47 @@
+package inmemory
+
+import (
+	"context"
+	"time"
+
+	"github.com/AEKDA/ozon_task/internal/api/graph/model"
+	"github.com/AEKDA/ozon_task/internal/service"
+)
+
+func (db *InMemoryDB) AddUser(ctx context.Context, id int64, input model.RegisterUserInput) (*model.User, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, ok := db.users[id]; ok {
+		return nil, service.Errorf(service.CodeValidation, "user %d is already registered", id)
+	}
+	for _, v := range db.users {
+		if v.Name == input.Name {
+			return nil, service.Errorf(service.CodeValidation, "user name %q is already taken", input.Name)
+		}
+	}
+
+	user := User{
+		ID:        id,
+		Name:      input.Name,
+		CreatedAt: time.Now(),
+	}
+	db.users[user.ID] = user
+
+	modelUser := user.toModel()
+	return &modelUser, nil
+}
+
+func (db *InMemoryDB) GetUsersByIDs(ctx context.Context, ids []int64) (map[int64]model.User, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	users := make(map[int64]model.User, len(ids))
+	for _, id := range ids {
+		if user, ok := db.users[id]; ok {
+			users[id] = user.toModel()
+		}
+	}
+
+	return users, nil
+}
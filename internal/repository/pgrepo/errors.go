@@ -0,0 +1,31 @@
+package pgrepo
+
+import (
+	"errors"
+
+	"github.com/AEKDA/ozon_task/internal/service"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	foreignKeyViolation = "23503"
+	uniqueViolation     = "23505"
+)
+
+// notFound turns pgx.ErrNoRows into a NOT_FOUND error naming the missing row.
+func notFound(err error, format string, args ...interface{}) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return service.Errorf(service.CodeNotFound, format, args...)
+	}
+	return err
+}
+
+// authorNotFound reports an insert referencing a missing user as NOT_FOUND.
+func authorNotFound(err error, authorID int64) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolation {
+		return service.Errorf(service.CodeNotFound, "user %d not found", authorID)
+	}
+	return err
+}
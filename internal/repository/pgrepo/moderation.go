@@ -0,0 +1,56 @@
+package pgrepo
+
+import (
+	"context"
+	"time"
+
+	"github.com/AEKDA/ozon_task/internal/api/graph/model"
+)
+
+func (r *Repository) HideComment(ctx context.Context, commentID int64, hidden bool) (*model.Comment, error) {
+	comment, err := scanComment(r.db.QueryRow(ctx,
+		"UPDATE comments SET hidden = $2 WHERE id = $1 RETURNING "+commentColumns,
+		commentID, hidden))
+	if err != nil {
+		return nil, notFound(err, "comment %d not found", commentID)
+	}
+	return &comment, nil
+}
+
+// PinComment pins the comment on its own post, so a comment can never be
+// pinned on another post. Unpinning only clears the pin if it is still this
+// comment.
+func (r *Repository) PinComment(ctx context.Context, commentID int64, pinned bool) (*model.Post, error) {
+	query := `UPDATE posts SET pinned_comment_id = $1
+		WHERE id = (SELECT post_id FROM comments WHERE id = $1) RETURNING ` + postColumns
+	if !pinned {
+		query = `UPDATE posts SET pinned_comment_id = CASE WHEN pinned_comment_id = $1 THEN NULL ELSE pinned_comment_id END
+		WHERE id = (SELECT post_id FROM comments WHERE id = $1) RETURNING ` + postColumns
+	}
+
+	post, err := scanPost(r.db.QueryRow(ctx, query, commentID))
+	if err != nil {
+		return nil, notFound(err, "comment %d not found", commentID)
+	}
+	return &post, nil
+}
+
+func (r *Repository) LockPost(ctx context.Context, postID int64, locked bool) (*model.Post, error) {
+	post, err := scanPost(r.db.QueryRow(ctx,
+		"UPDATE posts SET locked = $2 WHERE id = $1 RETURNING "+postColumns,
+		postID, locked))
+	if err != nil {
+		return nil, notFound(err, "post %d not found", postID)
+	}
+	return &post, nil
+}
+
+func (r *Repository) BanUser(ctx context.Context, userID int64, until time.Time) (*model.User, error) {
+	user, err := scanUser(r.db.QueryRow(ctx,
+		"UPDATE users SET banned_until = $2 WHERE id = $1 RETURNING "+userColumns,
+		userID, until))
+	if err != nil {
+		return nil, notFound(err, "user %d not found", userID)
+	}
+	return &user, nil
+}
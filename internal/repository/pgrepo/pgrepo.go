@@ -5,11 +5,12 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/AEKDA/ozon_task/internal/api/graph/model"
 	"github.com/AEKDA/ozon_task/internal/logger"
 	"github.com/AEKDA/ozon_task/internal/repository/cursor"
-	"github.com/jackc/pgx/v5"
+	"github.com/AEKDA/ozon_task/internal/service"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -22,100 +23,137 @@ func New(db *pgxpool.Pool, log *logger.Logger) *Repository {
 	return &Repository{db: db, logger: log}
 }
 
-func (r *Repository) AddPost(ctx context.Context, post model.AddPostInput) (*model.Post, error) {
-	var newPost model.Post
-	err := r.db.QueryRow(ctx,
-		"INSERT INTO posts (title, content, author, allow_comments) VALUES ($1, $2, $3, $4) RETURNING id, title, content, created_at, author, allow_comments",
-		post.Title, post.Content, post.Author, post.AllowComments).Scan(
-		&newPost.ID, &newPost.Title, &newPost.Content, &newPost.CreatedAt, &newPost.Author, &newPost.AllowComments)
+const (
+	postColumns    = "id, title, content, author_id, allow_comments, locked, pinned_comment_id, created_at, edited_at"
+	commentColumns = "id, post_id, content, author_id, created_at, reply_to, edited_at, deleted_at, hidden"
+)
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanPost reads postColumns followed by the extra destinations.
+func scanPost(row rowScanner, extra ...interface{}) (model.Post, error) {
+	var post model.Post
+	dest := append([]interface{}{&post.ID, &post.Title, &post.Content, &post.AuthorID, &post.AllowComments, &post.Locked, &post.PinnedCommentID, &post.CreatedAt, &post.EditedAt}, extra...)
+	err := row.Scan(dest...)
+	return post, err
+}
+
+// scanComment reads commentColumns followed by the extra destinations.
+func scanComment(row rowScanner, extra ...interface{}) (model.Comment, error) {
+	var comment model.Comment
+	var deletedAt *time.Time
+	dest := append([]interface{}{&comment.ID, &comment.PostID, &comment.Content, &comment.AuthorID, &comment.CreatedAt, &comment.ReplyTo, &comment.EditedAt, &deletedAt, &comment.IsHidden}, extra...)
+	err := row.Scan(dest...)
+	comment.IsDeleted = deletedAt != nil
+	if comment.IsHidden {
+		comment.Content = ""
+	}
+	return comment, err
+}
+
+func (r *Repository) AddPost(ctx context.Context, authorID int64, post model.AddPostInput) (*model.Post, error) {
+	tx, err := r.db.Begin(ctx)
 	if err != nil {
 		return nil, err
 	}
+
+	if err := checkAuthor(ctx, tx, authorID); err != nil {
+		tx.Rollback(ctx)
+		return nil, err
+	}
+
+	newPost, err := scanPost(tx.QueryRow(ctx,
+		withPostRevision("INSERT INTO posts (title, content, author_id, allow_comments) VALUES ($1, $2, $3, $4)", "author_id"),
+		post.Title, post.Content, authorID, post.AllowComments))
+	if err != nil {
+		tx.Rollback(ctx)
+		return nil, authorNotFound(err, authorID)
+	}
+
+	tx.Commit(ctx)
 	return &newPost, nil
 }
 
 func (r *Repository) GetPostByID(ctx context.Context, id int64) (*model.Post, error) {
-	var post model.Post
-	err := r.db.QueryRow(ctx,
-		"SELECT id, title, content, author, allow_comments,created_at FROM posts WHERE id=$1", id).Scan(
-		&post.ID, &post.Title, &post.Content, &post.Author, &post.AllowComments, &post.CreatedAt)
+	post, err := scanPost(r.db.QueryRow(ctx,
+		"SELECT "+postColumns+" FROM posts WHERE id=$1", id))
 	if err != nil {
-		if err == pgx.ErrNoRows {
-			return nil, pgx.ErrNoRows
-		}
-		return nil, err
+		return nil, notFound(err, "post %d not found", id)
 	}
 
 	return &post, nil
 }
 
-func (r *Repository) GetCommentsByPostIDs(ctx context.Context, postIDs []int64, first int, after *string) (map[int64]model.CommentConnection, error) {
+// GetCommentsByPostIDs numbers the comments of every post separately, so each
+// post in a dataloader batch gets its own first+1 window and PageInfo.
+func (r *Repository) GetCommentsByPostIDs(ctx context.Context, postIDs []int64, page cursor.Page, rootsOnly bool) (map[int64]model.CommentConnection, error) {
 	comments := make(map[int64][]model.Comment)
 	var args []interface{}
 
 	args = append(args, postIDs)
-	query := fmt.Sprintf("SELECT id, content, author, created_at, reply_to, post_id FROM comments WHERE post_id= ANY($%d::int[]) ", len(args))
+	query := fmt.Sprintf(`SELECT %s,
+		row_number() OVER (PARTITION BY post_id ORDER BY id %s) AS position
+		FROM comments WHERE post_id = ANY($%d::int[])`, commentColumns, page.Order(), len(args))
 
-	if after != nil {
-		startID, err := cursor.Decode(after)
-		if err != nil {
-			return nil, fmt.Errorf("invalid cursor: %v", err)
-		}
-		args = append(args, *startID)
-		query = fmt.Sprintf("%s AND id > $%d", query, len(args))
+	if rootsOnly {
+		query = fmt.Sprintf("%s AND reply_to IS NULL", query)
 	}
 
-	args = append(args, first+1)
-	query = fmt.Sprintf("%s ORDER BY id ASC LIMIT $%d", query, len(args))
+	query, args, err := appendPageBounds(query, args, page, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	args = append(args, page.Limit+1)
+	query = fmt.Sprintf(`SELECT %s FROM (%s) AS post_comments
+		WHERE position <= $%d ORDER BY post_id, id %s`, commentColumns, query, len(args), page.Order())
 
 	rows, err := r.db.Query(ctx, query, args...)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return make(map[int64]model.CommentConnection), nil
-		}
 		return nil, fmt.Errorf("query failed: %v", err)
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		var comment model.Comment
-		var postID int64
-		if err := rows.Scan(&comment.ID, &comment.Content, &comment.Author, &comment.CreatedAt, &comment.ReplyTo, &postID); err != nil {
+		comment, err := scanComment(rows)
+		if err != nil {
 			return nil, fmt.Errorf("row scan failed: %v", err)
 		}
-		comments[postID] = append(comments[postID], comment)
+		comments[comment.PostID] = append(comments[comment.PostID], comment)
 	}
 
 	if rows.Err() != nil {
 		return nil, fmt.Errorf("rows error: %v", rows.Err())
 	}
 
-	ans := make(map[int64]model.CommentConnection, len(comments))
-	for k := range comments {
-		ans[k] = toCommentConnection(comments[k], first)
+	ans := make(map[int64]model.CommentConnection, len(postIDs))
+	for _, postID := range postIDs {
+		ans[postID] = toCommentConnection(comments[postID], page)
 	}
 
 	return ans, nil
 }
 
-func (r *Repository) GetCommentsByPostID(ctx context.Context, postID int64, first int, after *string) (model.CommentConnection, error) {
+func (r *Repository) GetCommentsByPostID(ctx context.Context, postID int64, page cursor.Page, rootsOnly bool) (model.CommentConnection, error) {
 	var comments []model.Comment
 	var args []interface{}
 
 	args = append(args, postID)
-	query := fmt.Sprintf("SELECT id, content, author, created_at, reply_to FROM comments WHERE post_id=$%d ", len(args))
+	query := fmt.Sprintf("SELECT %s FROM comments WHERE post_id=$%d ", commentColumns, len(args))
 
-	if after != nil {
-		startID, err := cursor.Decode(after)
-		if err != nil {
-			return model.CommentConnection{}, fmt.Errorf("invalid cursor: %v", err)
-		}
-		args = append(args, *startID)
-		query = fmt.Sprintf("%s AND id > $%d", query, len(args))
+	if rootsOnly {
+		query = fmt.Sprintf("%s AND reply_to IS NULL", query)
 	}
 
-	args = append(args, first+1)
-	query = fmt.Sprintf("%s ORDER BY id ASC LIMIT $%d", query, len(args))
+	query, args, err := appendPageBounds(query, args, page, "id")
+	if err != nil {
+		return model.CommentConnection{}, err
+	}
+
+	args = append(args, page.Limit+1)
+	query = fmt.Sprintf("%s ORDER BY id %s LIMIT $%d", query, page.Order(), len(args))
 
 	rows, err := r.db.Query(ctx, query, args...)
 	if err != nil {
@@ -127,8 +165,8 @@ func (r *Repository) GetCommentsByPostID(ctx context.Context, postID int64, firs
 	defer rows.Close()
 
 	for rows.Next() {
-		var comment model.Comment
-		if err := rows.Scan(&comment.ID, &comment.Content, &comment.Author, &comment.CreatedAt, &comment.ReplyTo); err != nil {
+		comment, err := scanComment(rows)
+		if err != nil {
 			return model.CommentConnection{}, fmt.Errorf("row scan failed: %v", err)
 		}
 		comments = append(comments, comment)
@@ -138,10 +176,159 @@ func (r *Repository) GetCommentsByPostID(ctx context.Context, postID int64, firs
 		return model.CommentConnection{}, fmt.Errorf("rows error: %v", rows.Err())
 	}
 
-	return toCommentConnection(comments, first), nil
+	return toCommentConnection(comments, page), nil
+}
+
+func (r *Repository) GetCommentsByIDs(ctx context.Context, ids []int64) (map[int64]model.Comment, error) {
+	rows, err := r.db.Query(ctx,
+		"SELECT "+commentColumns+" FROM comments WHERE id = ANY($1::int[])", ids)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	comments := make(map[int64]model.Comment, len(ids))
+	for rows.Next() {
+		comment, err := scanComment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("row scan failed: %v", err)
+		}
+		comments[comment.ID] = comment
+	}
+
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("rows error: %v", rows.Err())
+	}
+
+	return comments, nil
+}
+
+func (r *Repository) GetRepliesByCommentIDs(ctx context.Context, commentIDs []int64, page cursor.Page) (map[int64]model.CommentConnection, error) {
+	replies := make(map[int64][]model.Comment)
+	var args []interface{}
+
+	args = append(args, commentIDs)
+	query := fmt.Sprintf(`SELECT %s,
+		row_number() OVER (PARTITION BY reply_to ORDER BY id %s) AS position
+		FROM comments WHERE reply_to = ANY($%d::int[])`, commentColumns, page.Order(), len(args))
+
+	query, args, err := appendPageBounds(query, args, page, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	args = append(args, page.Limit+1)
+	query = fmt.Sprintf(`SELECT %s FROM (%s) AS replies
+		WHERE position <= $%d ORDER BY reply_to, id %s`, commentColumns, query, len(args), page.Order())
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		comment, err := scanComment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("row scan failed: %v", err)
+		}
+		replies[*comment.ReplyTo] = append(replies[*comment.ReplyTo], comment)
+	}
+
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("rows error: %v", rows.Err())
+	}
+
+	ans := make(map[int64]model.CommentConnection, len(commentIDs))
+	for _, commentID := range commentIDs {
+		ans[commentID] = toCommentConnection(replies[commentID], page)
+	}
+
+	return ans, nil
+}
+
+func (r *Repository) CountReplies(ctx context.Context, commentIDs []int64) (map[int64]int, error) {
+	rows, err := r.db.Query(ctx,
+		"SELECT reply_to, count(*) FROM comments WHERE reply_to = ANY($1::int[]) GROUP BY reply_to", commentIDs)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[int64]int, len(commentIDs))
+	for _, id := range commentIDs {
+		counts[id] = 0
+	}
+	for rows.Next() {
+		var id int64
+		var count int
+		if err := rows.Scan(&id, &count); err != nil {
+			return nil, fmt.Errorf("row scan failed: %v", err)
+		}
+		counts[id] = count
+	}
+
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("rows error: %v", rows.Err())
+	}
+
+	return counts, nil
+}
+
+// GetCommentDepths walks up the reply_to chain of every requested comment
+// with a recursive CTE; root comments have depth 0.
+func (r *Repository) GetCommentDepths(ctx context.Context, ids []int64) (map[int64]int, error) {
+	rows, err := r.db.Query(ctx,
+		`WITH RECURSIVE ancestors AS (
+			SELECT id, reply_to, 0 AS depth FROM comments WHERE id = ANY($1::int[])
+			UNION ALL
+			SELECT a.id, c.reply_to, a.depth + 1 FROM ancestors a JOIN comments c ON c.id = a.reply_to
+		)
+		SELECT id, max(depth) FROM ancestors GROUP BY id`, ids)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	depths := make(map[int64]int, len(ids))
+	for rows.Next() {
+		var id int64
+		var depth int
+		if err := rows.Scan(&id, &depth); err != nil {
+			return nil, fmt.Errorf("row scan failed: %v", err)
+		}
+		depths[id] = depth
+	}
+
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("rows error: %v", rows.Err())
+	}
+
+	return depths, nil
+}
+
+// appendPageBounds restricts query to the rows whose column lies between the
+// page cursors.
+func appendPageBounds(query string, args []interface{}, page cursor.Page, column string) (string, []interface{}, error) {
+	after, before, err := page.Bounds()
+	if err != nil {
+		return "", nil, err
+	}
+
+	if after != nil {
+		args = append(args, *after)
+		query = fmt.Sprintf("%s AND %s > $%d", query, column, len(args))
+	}
+	if before != nil {
+		args = append(args, *before)
+		query = fmt.Sprintf("%s AND %s < $%d", query, column, len(args))
+	}
+
+	return query, args, nil
 }
 
-func toCommentConnection(comments []model.Comment, first int) model.CommentConnection {
+func toCommentConnection(comments []model.Comment, page cursor.Page) model.CommentConnection {
+	comments, pageInfo := cursor.Paginate(comments, page, func(c model.Comment) string { return cursor.Encode(c.ID) })
 
 	edges := make([]model.CommentEdge, 0, len(comments))
 	for _, comment := range comments {
@@ -151,163 +338,292 @@ func toCommentConnection(comments []model.Comment, first int) model.CommentConne
 		})
 	}
 
-	var pageInfo model.PageInfo
-	if len(edges) > first {
-		pageInfo = model.PageInfo{
-			HasNextPage: true,
-			StartCursor: edges[0].Cursor,
-			EndCursor:   edges[first-1].Cursor,
-		}
-		edges = edges[:first] // Return only the first 'first' comments
-	} else if len(edges) > 0 {
-		pageInfo = model.PageInfo{
-			HasNextPage: false,
-			StartCursor: edges[0].Cursor,
-			EndCursor:   edges[len(edges)-1].Cursor,
-		}
-	}
-
 	return model.CommentConnection{
 		Edges:    edges,
 		PageInfo: pageInfo,
 	}
 }
 
-func (r *Repository) GetPosts(ctx context.Context, first int, after *string) (*model.PostConnection, error) {
-	var rows pgx.Rows
-	var err error
+type postOrder struct {
+	key    string
+	desc   bool
+	isTime bool
+}
 
-	cursorID, err := cursor.Decode(after)
+// postOrders maps every feed order to its sort key expression over posts p.
+// Posts without comments are ordered by their creation time in
+// LAST_COMMENT_AT_DESC.
+var postOrders = map[model.PostOrder]postOrder{
+	model.PostOrderCreatedAtAsc:      {key: "p.created_at", isTime: true},
+	model.PostOrderCreatedAtDesc:     {key: "p.created_at", desc: true, isTime: true},
+	model.PostOrderCommentCountDesc:  {key: "(SELECT count(*) FROM comments c WHERE c.post_id = p.id)", desc: true},
+	model.PostOrderLastCommentAtDesc: {key: "(SELECT coalesce(max(c.created_at), p.created_at) FROM comments c WHERE c.post_id = p.id)", desc: true, isTime: true},
+}
+
+func (o postOrder) arg(key cursor.Key) interface{} {
+	if o.isTime {
+		return time.UnixMicro(key.Value)
+	}
+	return key.Value
+}
+
+func (r *Repository) GetPosts(ctx context.Context, page cursor.Page, order model.PostOrder, filter *model.PostFilter) (*model.PostConnection, error) {
+	sortOrder, ok := postOrders[order]
+	if !ok {
+		return nil, fmt.Errorf("unknown post order %s", order)
+	}
+
+	afterKey, beforeKey, err := page.KeyBounds()
 	if err != nil {
 		return nil, err
 	}
 
-	if cursorID != nil {
-		rows, err = r.db.Query(ctx,
-			"SELECT id, title, content, author, allow_comments, created_at FROM posts WHERE id > $1 ORDER BY id ASC LIMIT $2",
-			*cursorID, first)
-	} else {
-		rows, err = r.db.Query(ctx,
-			"SELECT id, title, content, author, allow_comments, created_at FROM posts ORDER BY id ASC LIMIT $1",
-			first)
+	var args []interface{}
+	query := fmt.Sprintf("SELECT %s, %s AS sort_key FROM posts p WHERE true", postColumns, sortOrder.key)
+	if filter != nil {
+		query, args = appendPostFilter(query, args, *filter)
+	}
+	query = fmt.Sprintf("SELECT %s, sort_key FROM (%s) AS feed WHERE true", postColumns, query)
+
+	// Rows following a cursor in feed order have a greater key in ascending
+	// feeds and a smaller key in descending ones.
+	following, preceding := ">", "<"
+	if sortOrder.desc {
+		following, preceding = preceding, following
+	}
+	if afterKey != nil {
+		args = append(args, sortOrder.arg(*afterKey), afterKey.ID)
+		query = fmt.Sprintf("%s AND (sort_key, id) %s ($%d, $%d)", query, following, len(args)-1, len(args))
+	}
+	if beforeKey != nil {
+		args = append(args, sortOrder.arg(*beforeKey), beforeKey.ID)
+		query = fmt.Sprintf("%s AND (sort_key, id) %s ($%d, $%d)", query, preceding, len(args)-1, len(args))
+	}
+
+	direction := "ASC"
+	if sortOrder.desc != page.Backward {
+		direction = "DESC"
 	}
+	args = append(args, page.Limit+1)
+	query = fmt.Sprintf("%s ORDER BY sort_key %s, id %s LIMIT $%d", query, direction, direction, len(args))
+
+	rows, err := r.db.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var posts []model.Post
+	var edges []model.PostEdge
 	for rows.Next() {
-		var post model.Post
-		if err := rows.Scan(&post.ID, &post.Title, &post.Content, &post.Author, &post.AllowComments, &post.CreatedAt); err != nil {
+		var sortKey interface{}
+		post, err := scanPost(rows, &sortKey)
+		if err != nil {
 			return nil, err
 		}
-		posts = append(posts, post)
-	}
 
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
+		key := cursor.Key{ID: post.ID}
+		switch v := sortKey.(type) {
+		case time.Time:
+			key.Value = v.UnixMicro()
+		case int64:
+			key.Value = v
+		}
 
-	edges := make([]model.PostEdge, len(posts))
-	for i, post := range posts {
-		edges[i] = model.PostEdge{
-			Cursor: cursor.Encode(post.ID),
+		edges = append(edges, model.PostEdge{
+			Cursor: cursor.EncodeKey(key),
 			Node:   post,
-		}
+		})
 	}
 
-	pageInfo := model.PageInfo{
-		HasNextPage: len(posts) == first,
-	}
-	if len(edges) > 0 {
-		pageInfo.StartCursor = edges[0].Cursor
-		pageInfo.EndCursor = edges[len(edges)-1].Cursor
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
+	edges, pageInfo := cursor.Paginate(edges, page, func(e model.PostEdge) string { return e.Cursor })
+
 	return &model.PostConnection{
 		Edges:    edges,
 		PageInfo: pageInfo,
 	}, nil
 }
 
+// appendPostFilter restricts query over posts p to the posts matching filter.
+// The creation time range is half-open: [createdAfter, createdBefore).
+func appendPostFilter(query string, args []interface{}, filter model.PostFilter) (string, []interface{}) {
+	if filter.AuthorID != nil {
+		args = append(args, *filter.AuthorID)
+		query = fmt.Sprintf("%s AND p.author_id = $%d", query, len(args))
+	}
+	if filter.CreatedAfter != nil {
+		args = append(args, *filter.CreatedAfter)
+		query = fmt.Sprintf("%s AND p.created_at >= $%d", query, len(args))
+	}
+	if filter.CreatedBefore != nil {
+		args = append(args, *filter.CreatedBefore)
+		query = fmt.Sprintf("%s AND p.created_at < $%d", query, len(args))
+	}
+	if filter.AllowComments != nil {
+		args = append(args, *filter.AllowComments)
+		query = fmt.Sprintf("%s AND p.allow_comments = $%d", query, len(args))
+	}
+
+	return query, args
+}
+
 func (r *Repository) SetCommentPremission(ctx context.Context, postID int64, allow bool) (*model.Post, error) {
-	var post model.Post
-	err := r.db.QueryRow(ctx,
-		"UPDATE posts SET allow_comments = $1 WHERE id = $2 RETURNING id, title, content, author, allow_comments, created_at",
-		allow, postID).Scan(&post.ID, &post.Title, &post.Content, &post.Author, &post.AllowComments, &post.CreatedAt)
+	post, err := scanPost(r.db.QueryRow(ctx,
+		"UPDATE posts SET allow_comments = $1 WHERE id = $2 RETURNING "+postColumns,
+		allow, postID))
 	if err != nil {
-		return nil, err
+		return nil, notFound(err, "post %d not found", postID)
 	}
 	return &post, nil
 }
 
-func (r *Repository) AddCommentToPost(ctx context.Context, commentInput model.AddCommentInput) (*model.Comment, error) {
+func (r *Repository) AddCommentToPost(ctx context.Context, authorID int64, commentInput model.AddCommentInput) (*model.Comment, error) {
 	tx, err := r.db.Begin(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	var commentAllow bool
+	if err := checkAuthor(ctx, tx, authorID); err != nil {
+		tx.Rollback(ctx)
+		return nil, err
+	}
+
+	// FOR SHARE keeps lockPost and setCommentPremission from committing
+	// between the check and the insert.
+	var commentAllow, postLocked bool
 	err = tx.QueryRow(ctx,
-		"SELECT allow_comments from posts where id = $1",
+		"SELECT allow_comments, locked FROM posts WHERE id = $1 FOR SHARE",
 		commentInput.PostID).Scan(
-		&commentAllow)
+		&commentAllow, &postLocked)
 	if err != nil {
 		tx.Rollback(ctx)
-		return nil, err
+		return nil, notFound(err, "post %d not found", commentInput.PostID)
 	}
 	if !commentAllow {
 		tx.Rollback(ctx)
-		return nil, fmt.Errorf("comments are not allowed")
+		return nil, service.ErrCommentsDisabled
+	}
+	if postLocked {
+		tx.Rollback(ctx)
+		return nil, service.ErrPostLocked
 	}
 
-	var newComment model.Comment
-	err = tx.QueryRow(ctx,
-		"INSERT INTO comments (post_id, content, author) VALUES ($1, $2, $3) RETURNING id, content, author, created_at",
-		commentInput.PostID, commentInput.Content, commentInput.Author).Scan(
-		&newComment.ID, &newComment.Content, &newComment.Author, &newComment.CreatedAt)
+	newComment, err := scanComment(tx.QueryRow(ctx,
+		withCommentRevision("INSERT INTO comments (post_id, content, author_id) VALUES ($1, $2, $3)", "author_id"),
+		commentInput.PostID, commentInput.Content, authorID))
 	if err != nil {
 		tx.Rollback(ctx)
-		return nil, err
+		return nil, authorNotFound(err, authorID)
 	}
 
 	tx.Commit(ctx)
 	return &newComment, nil
 }
 
-func (r *Repository) AddReplyToComment(ctx context.Context, commentInput model.AddReplyInput) (*model.Comment, error) {
+func (r *Repository) AddReplyToComment(ctx context.Context, authorID int64, commentInput model.AddReplyInput) (*model.Comment, error) {
 	tx, err := r.db.Begin(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	var commentAllow bool
+	if err := checkAuthor(ctx, tx, authorID); err != nil {
+		tx.Rollback(ctx)
+		return nil, err
+	}
+
+	var commentAllow, postLocked, commentDeleted bool
 	err = tx.QueryRow(ctx,
-		"SELECT allow_comments from posts where id = (select post_id from comments where comments.id = $1)",
+		`SELECT p.allow_comments, p.locked, c.deleted_at IS NOT NULL
+		FROM comments c JOIN posts p ON p.id = c.post_id WHERE c.id = $1 FOR SHARE OF p`,
 		commentInput.CommentID).Scan(
-		&commentAllow)
+		&commentAllow, &postLocked, &commentDeleted)
 	if err != nil {
 		tx.Rollback(ctx)
-		return nil, err
+		return nil, notFound(err, "comment %d not found", commentInput.CommentID)
 	}
 	if !commentAllow {
 		tx.Rollback(ctx)
-		return nil, fmt.Errorf("comments are not allowed")
+		return nil, service.ErrCommentsDisabled
+	}
+	if postLocked {
+		tx.Rollback(ctx)
+		return nil, service.ErrPostLocked
+	}
+	if commentDeleted {
+		tx.Rollback(ctx)
+		return nil, service.Errorf(service.CodeValidation, "cannot reply to a deleted comment")
 	}
 
-	var newComment model.Comment
-	err = tx.QueryRow(ctx,
-		`INSERT INTO comments (post_id, content, author, reply_to) 
-		VALUES ((SELECT post_id FROM comments WHERE id=$1), $2, $3, $1) 
-		RETURNING id, content, author, created_at, reply_to`,
-		commentInput.CommentID, commentInput.Content, commentInput.Author).Scan(
-		&newComment.ID, &newComment.Content, &newComment.Author, &newComment.CreatedAt, &newComment.ReplyTo)
+	newComment, err := scanComment(tx.QueryRow(ctx,
+		withCommentRevision(`INSERT INTO comments (post_id, content, author_id, reply_to) 
+		VALUES ((SELECT post_id FROM comments WHERE id=$1), $2, $3, $1)`, "author_id"),
+		commentInput.CommentID, commentInput.Content, authorID))
 	if err != nil {
 		tx.Rollback(ctx)
-		return nil, err
+		return nil, authorNotFound(err, authorID)
 	}
 
 	tx.Commit(ctx)
 	return &newComment, nil
 }
+
+func (r *Repository) UpdatePost(ctx context.Context, editorID int64, input model.UpdatePostInput) (*model.Post, error) {
+	post, err := scanPost(r.db.QueryRow(ctx,
+		withPostRevision(`UPDATE posts SET title = coalesce($2, title), content = coalesce($3, content),
+		edited_at = now(), revision = revision + 1 WHERE id = $1`, "$4::integer"),
+		input.ID, input.Title, input.Content, editorID))
+	if err != nil {
+		return nil, notFound(err, "post %d not found", input.ID)
+	}
+	return &post, nil
+}
+
+func (r *Repository) DeletePost(ctx context.Context, postID int64) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, "DELETE FROM comments WHERE post_id = $1", postID)
+	if err != nil {
+		tx.Rollback(ctx)
+		return err
+	}
+
+	tag, err := tx.Exec(ctx, "DELETE FROM posts WHERE id = $1", postID)
+	if err != nil {
+		tx.Rollback(ctx)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		tx.Rollback(ctx)
+		return service.Errorf(service.CodeNotFound, "post %d not found", postID)
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *Repository) EditComment(ctx context.Context, editorID int64, input model.EditCommentInput) (*model.Comment, error) {
+	comment, err := scanComment(r.db.QueryRow(ctx,
+		withCommentRevision("UPDATE comments SET content = $2, edited_at = now(), revision = revision + 1 WHERE id = $1 AND deleted_at IS NULL", "$3::integer"),
+		input.ID, input.Content, editorID))
+	if err != nil {
+		return nil, notFound(err, "comment %d not found", input.ID)
+	}
+	return &comment, nil
+}
+
+// DeleteComment turns the comment into a tombstone: its content is erased but
+// the row is kept so replies still point at it.
+func (r *Repository) DeleteComment(ctx context.Context, commentID int64) (*model.Comment, error) {
+	comment, err := scanComment(r.db.QueryRow(ctx,
+		"UPDATE comments SET content = '', deleted_at = coalesce(deleted_at, now()) WHERE id = $1 RETURNING "+commentColumns,
+		commentID))
+	if err != nil {
+		return nil, notFound(err, "comment %d not found", commentID)
+	}
+	return &comment, nil
+}
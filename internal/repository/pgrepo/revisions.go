@@ -0,0 +1,145 @@
+package pgrepo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/AEKDA/ozon_task/internal/api/graph/model"
+	"github.com/AEKDA/ozon_task/internal/repository/cursor"
+)
+
+// withPostRevision wraps a statement writing one post so that the written
+// title and content are also recorded in post_revisions. editor is the SQL
+// expression for the user who wrote the revision: author_id for a new post,
+// a parameter for edits, which may be made by a moderator. The statement must
+// not have a RETURNING clause; the result has postColumns.
+func withPostRevision(statement, editor string) string {
+	return fmt.Sprintf(`WITH post AS (%s RETURNING *), revision AS (
+		INSERT INTO post_revisions (post_id, revision, title, content, author_id, created_at)
+		SELECT id, revision, title, content, %s, coalesce(edited_at, created_at) FROM post
+	) SELECT %s FROM post`, statement, editor, postColumns)
+}
+
+// withCommentRevision is withPostRevision for comments.
+func withCommentRevision(statement, editor string) string {
+	return fmt.Sprintf(`WITH comment AS (%s RETURNING *), revision AS (
+		INSERT INTO comment_revisions (comment_id, revision, content, author_id, created_at)
+		SELECT id, revision, content, %s, coalesce(edited_at, created_at) FROM comment
+	) SELECT %s FROM comment`, statement, editor, commentColumns)
+}
+
+func (r *Repository) GetPostRevisions(ctx context.Context, postIDs []int64, page cursor.Page) (map[int64]model.PostRevisionConnection, error) {
+	revisions := make(map[int64][]model.PostRevision)
+	var args []interface{}
+
+	args = append(args, postIDs)
+	query := fmt.Sprintf(`SELECT post_id, revision, title, content, author_id, created_at,
+		row_number() OVER (PARTITION BY post_id ORDER BY revision %s) AS position
+		FROM post_revisions WHERE post_id = ANY($%d::int[])`, page.Order(), len(args))
+
+	query, args, err := appendPageBounds(query, args, page, "revision")
+	if err != nil {
+		return nil, err
+	}
+
+	args = append(args, page.Limit+1)
+	query = fmt.Sprintf(`SELECT post_id, revision, title, content, author_id, created_at FROM (%s) AS revisions
+		WHERE position <= $%d ORDER BY post_id, revision %s`, query, len(args), page.Order())
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var postID int64
+		var revision model.PostRevision
+		if err := rows.Scan(&postID, &revision.Revision, &revision.Title, &revision.Content, &revision.AuthorID, &revision.CreatedAt); err != nil {
+			return nil, fmt.Errorf("row scan failed: %v", err)
+		}
+		revisions[postID] = append(revisions[postID], revision)
+	}
+
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("rows error: %v", rows.Err())
+	}
+
+	ans := make(map[int64]model.PostRevisionConnection, len(postIDs))
+	for _, postID := range postIDs {
+		nodes, pageInfo := cursor.Paginate(revisions[postID], page, func(r model.PostRevision) string { return cursor.Encode(int64(r.Revision)) })
+
+		edges := make([]model.PostRevisionEdge, 0, len(nodes))
+		for _, node := range nodes {
+			edges = append(edges, model.PostRevisionEdge{
+				Cursor: cursor.Encode(int64(node.Revision)),
+				Node:   node,
+			})
+		}
+
+		ans[postID] = model.PostRevisionConnection{
+			Edges:    edges,
+			PageInfo: pageInfo,
+		}
+	}
+
+	return ans, nil
+}
+
+func (r *Repository) GetCommentRevisions(ctx context.Context, commentIDs []int64, page cursor.Page) (map[int64]model.CommentRevisionConnection, error) {
+	revisions := make(map[int64][]model.CommentRevision)
+	var args []interface{}
+
+	args = append(args, commentIDs)
+	query := fmt.Sprintf(`SELECT comment_id, revision, content, author_id, created_at,
+		row_number() OVER (PARTITION BY comment_id ORDER BY revision %s) AS position
+		FROM comment_revisions WHERE comment_id = ANY($%d::int[])`, page.Order(), len(args))
+
+	query, args, err := appendPageBounds(query, args, page, "revision")
+	if err != nil {
+		return nil, err
+	}
+
+	args = append(args, page.Limit+1)
+	query = fmt.Sprintf(`SELECT comment_id, revision, content, author_id, created_at FROM (%s) AS revisions
+		WHERE position <= $%d ORDER BY comment_id, revision %s`, query, len(args), page.Order())
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var commentID int64
+		var revision model.CommentRevision
+		if err := rows.Scan(&commentID, &revision.Revision, &revision.Content, &revision.AuthorID, &revision.CreatedAt); err != nil {
+			return nil, fmt.Errorf("row scan failed: %v", err)
+		}
+		revisions[commentID] = append(revisions[commentID], revision)
+	}
+
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("rows error: %v", rows.Err())
+	}
+
+	ans := make(map[int64]model.CommentRevisionConnection, len(commentIDs))
+	for _, commentID := range commentIDs {
+		nodes, pageInfo := cursor.Paginate(revisions[commentID], page, func(r model.CommentRevision) string { return cursor.Encode(int64(r.Revision)) })
+
+		edges := make([]model.CommentRevisionEdge, 0, len(nodes))
+		for _, node := range nodes {
+			edges = append(edges, model.CommentRevisionEdge{
+				Cursor: cursor.Encode(int64(node.Revision)),
+				Node:   node,
+			})
+		}
+
+		ans[commentID] = model.CommentRevisionConnection{
+			Edges:    edges,
+			PageInfo: pageInfo,
+		}
+	}
+
+	return ans, nil
+}
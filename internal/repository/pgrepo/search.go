@@ -0,0 +1,124 @@
+package pgrepo
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/AEKDA/ozon_task/internal/api/graph/model"
+	"github.com/AEKDA/ozon_task/internal/repository/cursor"
+)
+
+// ts_headline marks the matches with control characters instead of HTML
+// tags, so the content can be escaped before the markers become <b></b>.
+// The markers are removed from the content beforehand so it cannot fake them.
+const (
+	matchStart      = "\x02"
+	matchStop       = "\x03"
+	headlineOptions = "StartSel=" + matchStart + ", StopSel=" + matchStop
+)
+
+var snippetTags = strings.NewReplacer(matchStart, "<b>", matchStop, "</b>")
+
+func escapeSnippet(snippet string) string {
+	return snippetTags.Replace(html.EscapeString(snippet))
+}
+
+// Search matches posts and comments against the search_vector columns and
+// pages through the hits by their position in the ranking.
+func (r *Repository) Search(ctx context.Context, query string, page cursor.Page) (*model.SearchConnection, error) {
+	after, _, err := page.Bounds()
+	if err != nil {
+		return nil, err
+	}
+
+	var offset int64
+	if after != nil {
+		offset = max(*after, 0)
+	}
+
+	rows, err := r.db.Query(ctx,
+		`SELECT kind, id, post_id, title, content, author_id, allow_comments, locked, pinned_comment_id, created_at, edited_at, reply_to, snippet FROM (
+			SELECT 'post' AS kind, p.id, p.id AS post_id, p.title, p.content, p.author_id, p.allow_comments, p.locked, p.pinned_comment_id,
+				p.created_at, p.edited_at, NULL::int AS reply_to,
+				ts_rank(p.search_vector, q) AS rank, ts_headline('simple', translate(p.title || ' ' || p.content, $4, ''), q, $5) AS snippet
+			FROM posts p, plainto_tsquery('simple', $1) q WHERE p.search_vector @@ q
+			UNION ALL
+			SELECT 'comment', c.id, c.post_id, NULL, c.content, c.author_id, NULL, NULL, NULL,
+				c.created_at, c.edited_at, c.reply_to,
+				ts_rank(c.search_vector, q), ts_headline('simple', translate(c.content, $4, ''), q, $5)
+			FROM comments c, plainto_tsquery('simple', $1) q WHERE c.search_vector @@ q AND NOT c.hidden
+		) AS hits
+		ORDER BY rank DESC, kind, id OFFSET $2 LIMIT $3`,
+		query, offset, page.Limit+1, matchStart+matchStop, headlineOptions)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	var edges []model.SearchEdge
+	for rows.Next() {
+		var (
+			kind          string
+			id            int64
+			postID        int64
+			title         *string
+			content       string
+			authorID      int64
+			allowComments *bool
+			locked        *bool
+			pinnedComment *int64
+			createdAt     time.Time
+			editedAt      *time.Time
+			replyTo       *int64
+			snippet       string
+			edge          model.SearchEdge
+		)
+		if err := rows.Scan(&kind, &id, &postID, &title, &content, &authorID, &allowComments, &locked, &pinnedComment, &createdAt, &editedAt, &replyTo, &snippet); err != nil {
+			return nil, fmt.Errorf("row scan failed: %v", err)
+		}
+		edge.Snippet = escapeSnippet(snippet)
+
+		switch kind {
+		case "post":
+			edge.Node = model.Post{
+				ID:              id,
+				Title:           *title,
+				Content:         content,
+				AuthorID:        authorID,
+				AllowComments:   *allowComments,
+				Locked:          *locked,
+				PinnedCommentID: pinnedComment,
+				CreatedAt:       createdAt,
+				EditedAt:        editedAt,
+			}
+		default:
+			edge.Node = model.Comment{
+				ID:        id,
+				PostID:    postID,
+				Content:   content,
+				AuthorID:  authorID,
+				CreatedAt: createdAt,
+				EditedAt:  editedAt,
+				ReplyTo:   replyTo,
+			}
+		}
+
+		offset++
+		edge.Cursor = cursor.Encode(offset)
+		edges = append(edges, edge)
+	}
+
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("rows error: %v", rows.Err())
+	}
+
+	edges, pageInfo := cursor.Paginate(edges, page, func(e model.SearchEdge) string { return e.Cursor })
+
+	return &model.SearchConnection{
+		Edges:    edges,
+		PageInfo: pageInfo,
+	}, nil
+}
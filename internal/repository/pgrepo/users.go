@@ -0,0 +1,78 @@
+package pgrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/AEKDA/ozon_task/internal/api/graph/model"
+	"github.com/AEKDA/ozon_task/internal/service"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const userColumns = "id, name, created_at, banned_until"
+
+func scanUser(row rowScanner) (model.User, error) {
+	var user model.User
+	err := row.Scan(&user.ID, &user.Name, &user.CreatedAt, &user.BannedUntil)
+	return user, err
+}
+
+func (r *Repository) AddUser(ctx context.Context, id int64, input model.RegisterUserInput) (*model.User, error) {
+	user, err := scanUser(r.db.QueryRow(ctx,
+		"INSERT INTO users (id, name) VALUES ($1, $2) RETURNING "+userColumns,
+		id, input.Name))
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			if pgErr.ConstraintName == "users_pkey" {
+				return nil, service.Errorf(service.CodeValidation, "user %d is already registered", id)
+			}
+			return nil, service.Errorf(service.CodeValidation, "user name %q is already taken", input.Name)
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *Repository) GetUsersByIDs(ctx context.Context, ids []int64) (map[int64]model.User, error) {
+	rows, err := r.db.Query(ctx,
+		"SELECT "+userColumns+" FROM users WHERE id = ANY($1::int[])", ids)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	users := make(map[int64]model.User, len(ids))
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("row scan failed: %v", err)
+		}
+		users[user.ID] = user
+	}
+
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("rows error: %v", rows.Err())
+	}
+
+	return users, nil
+}
+
+// checkAuthor rejects missing and banned authors. The row stays locked until
+// tx ends, so banAuthor can not commit between the check and the insert.
+func checkAuthor(ctx context.Context, tx pgx.Tx, authorID int64) error {
+	var bannedUntil *time.Time
+	err := tx.QueryRow(ctx,
+		"SELECT banned_until FROM users WHERE id = $1 FOR SHARE", authorID).Scan(&bannedUntil)
+	if err != nil {
+		return notFound(err, "user %d not found", authorID)
+	}
+	if bannedUntil != nil && bannedUntil.After(time.Now()) {
+		return service.AuthorBanned(*bannedUntil)
+	}
+
+	return nil
+}
@@ -0,0 +1,36 @@
+package server
+
+import (
+	"fmt"
+	"time"
+)
+
+type Config struct {
+	// AllowedOrigins lists the origins allowed to open a websocket, "*"
+	// allows any. When empty only same origin connections are accepted.
+	AllowedOrigins []string `env:"WS_ALLOWED_ORIGINS"`
+	// InitTimeout is how long a websocket client has to send connection_init.
+	InitTimeout time.Duration `env:"WS_INIT_TIMEOUT" envDefault:"10s"`
+	// PingInterval is how often idle websocket connections are pinged:
+	// ka messages for subscriptions-transport-ws, ping for graphql-transport-ws.
+	PingInterval time.Duration `env:"WS_PING_INTERVAL" envDefault:"10s"`
+	// HeartbeatInterval is how often idle SSE streams get a heartbeat.
+	HeartbeatInterval time.Duration `env:"SSE_HEARTBEAT_INTERVAL" envDefault:"15s"`
+
+	// ComplexityLimit is the highest complexity of an operation, where every
+	// field costs one and connections multiply the cost of their nodes by the
+	// page size.
+	ComplexityLimit int `env:"QUERY_COMPLEXITY_LIMIT" envDefault:"10000"`
+	// MaxDepth is the deepest nesting of fields an operation may select.
+	MaxDepth int `env:"QUERY_MAX_DEPTH" envDefault:"15"`
+}
+
+func (c Config) Validate() error {
+	if c.ComplexityLimit < 1 {
+		return fmt.Errorf("query complexity limit must be positive, got %d", c.ComplexityLimit)
+	}
+	if c.MaxDepth < 1 {
+		return fmt.Errorf("query max depth must be positive, got %d", c.MaxDepth)
+	}
+	return nil
+}
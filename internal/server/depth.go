@@ -0,0 +1,65 @@
+package server
+
+import (
+	"context"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/errcode"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+const errDepthLimit = "DEPTH_LIMIT_EXCEEDED"
+
+// depthLimit rejects operations whose selections are nested deeper than
+// limit. Introspection fields are not counted, like in the complexity limit.
+type depthLimit struct {
+	limit int
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.OperationContextMutator
+} = depthLimit{}
+
+func (d depthLimit) ExtensionName() string {
+	return "DepthLimit"
+}
+
+func (d depthLimit) Validate(schema graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (d depthLimit) MutateOperationContext(ctx context.Context, rc *graphql.OperationContext) *gqlerror.Error {
+	if depth := selectionDepth(rc.Operation.SelectionSet); depth > d.limit {
+		err := gqlerror.Errorf("operation has depth %d, which exceeds the limit of %d", depth, d.limit)
+		errcode.Set(err, errDepthLimit)
+		return err
+	}
+
+	return nil
+}
+
+// selectionDepth must be called on validated operations only, validation
+// rejects fragment cycles.
+func selectionDepth(selectionSet ast.SelectionSet) int {
+	depth := 0
+	for _, selection := range selectionSet {
+		switch s := selection.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(s.Name, "__") {
+				continue
+			}
+			depth = max(depth, 1+selectionDepth(s.SelectionSet))
+		case *ast.FragmentSpread:
+			if s.Definition != nil {
+				depth = max(depth, selectionDepth(s.Definition.SelectionSet))
+			}
+		case *ast.InlineFragment:
+			depth = max(depth, selectionDepth(s.SelectionSet))
+		}
+	}
+
+	return depth
+}
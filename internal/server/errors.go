@@ -0,0 +1,44 @@
+package server
+
+import (
+	"context"
+	"errors"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/AEKDA/ozon_task/internal/auth"
+	"github.com/AEKDA/ozon_task/internal/repository/cursor"
+	"github.com/AEKDA/ozon_task/internal/service"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// errorPresenter adds the code of domain errors to extensions.code so clients
+// can branch on it instead of on messages.
+func errorPresenter(ctx context.Context, err error) *gqlerror.Error {
+	gqlErr := graphql.DefaultErrorPresenter(ctx, err)
+
+	if code := errorCode(err); code != "" {
+		if gqlErr.Extensions == nil {
+			gqlErr.Extensions = make(map[string]interface{})
+		}
+		gqlErr.Extensions["code"] = code
+	}
+
+	return gqlErr
+}
+
+func errorCode(err error) service.ErrorCode {
+	var serviceErr *service.Error
+
+	switch {
+	case errors.As(err, &serviceErr):
+		return serviceErr.Code
+	case errors.Is(err, auth.ErrUnauthenticated):
+		return service.CodeUnauthenticated
+	case errors.Is(err, cursor.ErrInvalidCursor):
+		return service.CodeInvalidCursor
+	case errors.Is(err, cursor.ErrInvalidPage):
+		return service.CodeValidation
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,171 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/AEKDA/ozon_task/internal/api/graph/model"
+	"github.com/AEKDA/ozon_task/internal/logger"
+	"github.com/AEKDA/ozon_task/internal/repository/cursor"
+	"github.com/AEKDA/ozon_task/internal/repository/inmemory"
+	"github.com/AEKDA/ozon_task/internal/repository/pgrepo"
+	"github.com/AEKDA/ozon_task/internal/service"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+type repository interface {
+	service.PostRepository
+	service.CommentRepository
+	service.UserRepository
+	service.ModerationRepository
+}
+
+// backends returns the in-memory backend and, when TEST_DATABASE_URL points
+// at a migrated database, the Postgres one.
+func backends(t *testing.T) map[string]repository {
+	t.Helper()
+
+	repos := map[string]repository{"inmemory": inmemory.NewInMemoryDB()}
+
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Log("TEST_DATABASE_URL is not set, skipping the postgres backend")
+		return repos
+	}
+
+	pool, err := pgxpool.New(context.Background(), url)
+	if err != nil {
+		t.Fatalf("connect to postgres: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	repos["postgres"] = pgrepo.New(pool, &logger.Logger{Logger: zap.NewNop()})
+	return repos
+}
+
+// TestErrorCodes checks that clients see the same extensions.code whichever
+// backend failed.
+func TestErrorCodes(t *testing.T) {
+	for backend, repo := range backends(t) {
+		ctx := context.Background()
+
+		// A database may keep the users of earlier runs.
+		id := rand.Int63n(math.MaxInt32/2) + 1
+		name := fmt.Sprintf("errors-%d", id)
+		user, err := repo.AddUser(ctx, id, model.RegisterUserInput{Name: name})
+		if err != nil {
+			t.Fatalf("%s: add user: %v", backend, err)
+		}
+		banned, err := repo.AddUser(ctx, id+1, model.RegisterUserInput{Name: name + "-banned"})
+		if err != nil {
+			t.Fatalf("%s: add user: %v", backend, err)
+		}
+		if _, err := repo.BanUser(ctx, banned.ID, time.Now().Add(time.Hour)); err != nil {
+			t.Fatalf("%s: ban user: %v", backend, err)
+		}
+
+		disabled, err := repo.AddPost(ctx, user.ID, model.AddPostInput{Title: "disabled", Content: "disabled"})
+		if err != nil {
+			t.Fatalf("%s: add post: %v", backend, err)
+		}
+		locked, err := repo.AddPost(ctx, user.ID, model.AddPostInput{Title: "locked", Content: "locked", AllowComments: true})
+		if err != nil {
+			t.Fatalf("%s: add post: %v", backend, err)
+		}
+		if _, err := repo.LockPost(ctx, locked.ID, true); err != nil {
+			t.Fatalf("%s: lock post: %v", backend, err)
+		}
+		missing := locked.ID + 1_000_000
+
+		tests := []struct {
+			name string
+			err  func() error
+			want service.ErrorCode
+		}{
+			{
+				name: "missing post",
+				err: func() error {
+					_, err := repo.GetPostByID(ctx, missing)
+					return err
+				},
+				want: service.CodeNotFound,
+			},
+			{
+				name: "missing comment",
+				err: func() error {
+					_, err := repo.AddReplyToComment(ctx, user.ID, model.AddReplyInput{CommentID: missing, Content: "reply"})
+					return err
+				},
+				want: service.CodeNotFound,
+			},
+			{
+				name: "missing author",
+				err: func() error {
+					_, err := repo.AddPost(ctx, math.MaxInt32, model.AddPostInput{Title: "title", Content: "content"})
+					return err
+				},
+				want: service.CodeNotFound,
+			},
+			{
+				name: "taken user name",
+				err: func() error {
+					_, err := repo.AddUser(ctx, id+2, model.RegisterUserInput{Name: name})
+					return err
+				},
+				want: service.CodeValidation,
+			},
+			{
+				name: "comments disabled",
+				err: func() error {
+					_, err := repo.AddCommentToPost(ctx, user.ID, model.AddCommentInput{PostID: disabled.ID, Content: "comment"})
+					return err
+				},
+				want: service.CodeCommentsDisabled,
+			},
+			{
+				name: "post locked",
+				err: func() error {
+					_, err := repo.AddCommentToPost(ctx, user.ID, model.AddCommentInput{PostID: locked.ID, Content: "comment"})
+					return err
+				},
+				want: service.CodePostLocked,
+			},
+			{
+				name: "author banned",
+				err: func() error {
+					_, err := repo.AddCommentToPost(ctx, banned.ID, model.AddCommentInput{PostID: locked.ID, Content: "comment"})
+					return err
+				},
+				want: service.CodeAuthorBanned,
+			},
+			{
+				name: "invalid cursor",
+				err: func() error {
+					_, err := repo.GetCommentsByPostID(ctx, locked.ID, cursor.Page{Limit: 1, After: "not a cursor"}, false)
+					return err
+				},
+				want: service.CodeInvalidCursor,
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(backend+"/"+tt.name, func(t *testing.T) {
+				err := tt.err()
+				if err == nil {
+					t.Fatal("got no error")
+				}
+
+				gqlErr := errorPresenter(ctx, err)
+				if code := gqlErr.Extensions["code"]; code != tt.want {
+					t.Errorf("error %q has code %v, want %s", err, code, tt.want)
+				}
+			})
+		}
+	}
+}
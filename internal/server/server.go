@@ -1,22 +1,71 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"strings"
 
+	"github.com/99designs/gqlgen/graphql"
 	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
 	"github.com/99designs/gqlgen/graphql/playground"
 	"github.com/AEKDA/ozon_task/internal/api/graph"
+	"github.com/AEKDA/ozon_task/internal/auth"
+	"github.com/AEKDA/ozon_task/internal/sse"
+	"github.com/gorilla/websocket"
 )
 
-func New(resolver graph.ResolverRoot, host string, port uint32) *http.Server {
+// New assembles the GraphQL server. loaders provides the dataloaders the
+// resolvers use, persistedQueries is either automatic persisted queries or an
+// allowlist of operations.
+func New(resolver graph.ResolverRoot, loaders graphql.HandlerExtension, verifier *auth.Verifier, persistedQueries graphql.HandlerExtension, cfg Config, host string, port uint32) *http.Server {
 
-	srv := handler.NewDefaultServer(graph.NewExecutableSchema(
+	srv := handler.New(graph.NewExecutableSchema(
 		graph.Config{
-			Resolvers:  resolver,
-			Directives: graph.DirectiveRoot{Length: graph.LengthDirective},
+			Resolvers: resolver,
+			Directives: graph.DirectiveRoot{
+				Length: graph.LengthDirective,
+				Auth:   graph.AuthDirective,
+			},
+			Complexity: graph.NewComplexity(),
 		}))
 
+	srv.SetErrorPresenter(errorPresenter)
+
+	// The transport negotiates both subscriptions-transport-ws and
+	// graphql-transport-ws, each pinged by its own mechanism.
+	srv.AddTransport(transport.Websocket{
+		Upgrader: websocket.Upgrader{
+			CheckOrigin: checkOrigin(cfg.AllowedOrigins),
+		},
+		InitFunc:              websocketInit(verifier),
+		InitTimeout:           cfg.InitTimeout,
+		KeepAlivePingInterval: cfg.PingInterval,
+		PingPongInterval:      cfg.PingInterval,
+	})
+	// SSE must be matched before POST, which accepts any JSON body.
+	srv.AddTransport(sse.Transport{
+		HeartbeatInterval: cfg.HeartbeatInterval,
+		Resumable:         []string{"commentAdded"},
+	})
+	srv.AddTransport(transport.Options{})
+	srv.AddTransport(transport.GET{})
+	srv.AddTransport(transport.POST{})
+	srv.AddTransport(transport.MultipartForm{})
+
+	srv.SetQueryCache(lru.New(1000))
+
+	srv.Use(extension.Introspection{})
+	srv.Use(loaders)
+	srv.Use(extension.FixedComplexityLimit(cfg.ComplexityLimit))
+	srv.Use(depthLimit{limit: cfg.MaxDepth})
+	// After the limits, so that persisted.AutomaticPersistedQuery only stores
+	// operations passing them.
+	srv.Use(persistedQueries)
+
 	mux := http.NewServeMux()
 
 	mux.Handle("/", playground.Handler("GraphQL playground", "/query"))
@@ -27,3 +76,59 @@ func New(resolver graph.ResolverRoot, host string, port uint32) *http.Server {
 	}
 
 }
+
+// websocketInit authenticates a websocket connection by the Authorization
+// field of the connection_init payload. Browsers cannot set headers on the
+// upgrade request, so this is the only way for them to pass a token.
+func websocketInit(verifier *auth.Verifier) transport.WebsocketInitFunc {
+	return func(ctx context.Context, initPayload transport.InitPayload) (context.Context, *transport.InitPayload, error) {
+		// InitPayload.Authorization silently ignores a value that is not a
+		// string, which would let a malformed token through as anonymous.
+		for _, key := range []string{"Authorization", "authorization"} {
+			if value, ok := initPayload[key]; ok {
+				if _, ok := value.(string); !ok {
+					return ctx, nil, fmt.Errorf("connection_init %s must be a string", key)
+				}
+			}
+		}
+
+		authorization := initPayload.Authorization()
+		if authorization == "" {
+			return ctx, nil, nil
+		}
+
+		principal, err := verifier.Verify(auth.BearerToken(authorization))
+		if err != nil {
+			return ctx, nil, err
+		}
+
+		return auth.WithPrincipal(ctx, principal), nil, nil
+	}
+}
+
+// checkOrigin accepts websocket upgrades from the allowed origins. Without
+// any configured it keeps the same origin check of the upgrader.
+func checkOrigin(allowed []string) func(r *http.Request) bool {
+	if len(allowed) == 0 {
+		return nil
+	}
+
+	origins := make(map[string]struct{}, len(allowed))
+	for _, origin := range allowed {
+		if origin == "*" {
+			return func(r *http.Request) bool { return true }
+		}
+		origins[strings.TrimSuffix(origin, "/")] = struct{}{}
+	}
+
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			// Not a browser, the origin check does not protect anything.
+			return true
+		}
+
+		_, ok := origins[origin]
+		return ok
+	}
+}
@@ -0,0 +1,139 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AEKDA/ozon_task/internal/api/graph"
+	"github.com/AEKDA/ozon_task/internal/api/graph/model"
+	"github.com/AEKDA/ozon_task/internal/auth"
+	"github.com/AEKDA/ozon_task/internal/dataloader"
+	"github.com/AEKDA/ozon_task/internal/persisted"
+	"github.com/AEKDA/ozon_task/internal/pubsub"
+	"github.com/AEKDA/ozon_task/internal/repository/inmemory"
+	"github.com/AEKDA/ozon_task/internal/service"
+)
+
+// response is a GraphQL response with the parts the tests look at.
+type response struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string         `json:"message"`
+		Path       []any          `json:"path"`
+		Extensions map[string]any `json:"extensions"`
+	} `json:"errors"`
+}
+
+func query(t *testing.T, h http.Handler, q string) response {
+	t.Helper()
+
+	body, err := json.Marshal(map[string]string{"query": q})
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/query", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	var resp response
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode %q: %v", rec.Body.String(), err)
+	}
+	return resp
+}
+
+func TestLimits(t *testing.T) {
+	ctx := context.Background()
+	db := inmemory.NewInMemoryDB()
+	posts := service.NewPostService(db, db, db, pubsub.NewLocalBus(),
+		pubsub.Config{BufferSize: 16, Overflow: pubsub.DropOldest},
+		service.Config{MaxPageSize: 50, ViewerCountInterval: time.Second})
+
+	user, err := db.AddUser(ctx, 1, model.RegisterUserInput{Name: "alice"})
+	if err != nil {
+		t.Fatalf("AddUser: %v", err)
+	}
+	post, err := posts.AddPost(auth.WithPrincipal(ctx, &auth.Principal{UserID: user.ID, Role: auth.RoleUser}),
+		model.AddPostInput{Title: "title", Content: "content", AllowComments: true})
+	if err != nil {
+		t.Fatalf("AddPost: %v", err)
+	}
+
+	verifier, err := auth.NewVerifier(auth.Config{HMACSecret: "test-secret"})
+	if err != nil {
+		t.Fatalf("NewVerifier: %v", err)
+	}
+	resolver := &graph.Resolver{
+		PostService:       posts,
+		UserService:       service.NewUserService(db),
+		ModerationService: service.NewModerationService(db),
+	}
+	h := New(resolver, dataloader.Extension{Repo: db}, verifier,
+		persisted.AutomaticPersistedQuery{Cache: persisted.NewMemoryCache(100)},
+		Config{ComplexityLimit: 1000, MaxDepth: 8}, "", 0).Handler
+
+	tests := []struct {
+		name    string
+		query   string
+		message string
+		path    []any
+		code    string
+	}{
+		{
+			name:    "complexity",
+			query:   `{ posts(first: 50) { edges { node { comments(first: 50) { edges { node { id } } } } } } }`,
+			message: "operation has complexity 7651, which exceeds the limit of 1000",
+			code:    "COMPLEXITY_LIMIT_EXCEEDED",
+		},
+		{
+			name: "depth",
+			query: fmt.Sprintf(`{ post(id: %d) { comments(first: 1) { edges { node {
+				replies(first: 1) { edges { node { replies(first: 1) { edges { node { id } } } } } } } } } } }`, post.ID),
+			message: "operation has depth 11, which exceeds the limit of 8",
+			code:    errDepthLimit,
+		},
+		{
+			name:    "first above the page size",
+			query:   `{ posts(first: 51) { edges { node { id } } } }`,
+			message: "page size 51 exceeds the maximum of 50",
+			path:    []any{"posts"},
+			code:    string(service.CodeValidation),
+		},
+		{
+			name:    "last above the page size",
+			query:   fmt.Sprintf(`{ post(id: %d) { comments(last: 51) { edges { node { id } } } } }`, post.ID),
+			message: "page size 51 exceeds the maximum of 50",
+			path:    []any{"post", "comments"},
+			code:    string(service.CodeValidation),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := query(t, h, tt.query)
+
+			if len(resp.Errors) != 1 {
+				t.Fatalf("got errors %+v, want one", resp.Errors)
+			}
+			got := resp.Errors[0]
+			if got.Message != tt.message {
+				t.Errorf("got message %q, want %q", got.Message, tt.message)
+			}
+			if !slices.Equal(got.Path, tt.path) {
+				t.Errorf("got path %v, want %v", got.Path, tt.path)
+			}
+			if code := got.Extensions["code"]; code != tt.code {
+				t.Errorf("got extensions.code %v, want %s", code, tt.code)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+
+	"github.com/AEKDA/ozon_task/internal/auth"
+)
+
+// authorizePost allows the author of the post and moderators to change it.
+func (s *PostService) authorizePost(ctx context.Context, postID int64) error {
+	principal := auth.ForContext(ctx)
+	if principal == nil {
+		return auth.ErrUnauthenticated
+	}
+	if principal.IsModerator() {
+		return nil
+	}
+
+	post, err := s.postRepo.GetPostByID(ctx, postID)
+	if err != nil {
+		return err
+	}
+	if post.AuthorID != principal.UserID {
+		return &Error{Code: CodeForbidden, Message: "only the author of the post or a moderator can change it"}
+	}
+
+	return nil
+}
+
+// authorizeComment allows the author of the comment and moderators to change it.
+func (s *PostService) authorizeComment(ctx context.Context, commentID int64) error {
+	principal := auth.ForContext(ctx)
+	if principal == nil {
+		return auth.ErrUnauthenticated
+	}
+	if principal.IsModerator() {
+		return nil
+	}
+
+	comments, err := s.commentRepo.GetCommentsByIDs(ctx, []int64{commentID})
+	if err != nil {
+		return err
+	}
+	comment, ok := comments[commentID]
+	if !ok {
+		return Errorf(CodeNotFound, "comment %d not found", commentID)
+	}
+	if comment.AuthorID != principal.UserID {
+		return &Error{Code: CodeForbidden, Message: "only the author of the comment or a moderator can change it"}
+	}
+
+	return nil
+}
+
+func requireModerator(ctx context.Context) error {
+	principal := auth.ForContext(ctx)
+	if principal == nil {
+		return auth.ErrUnauthenticated
+	}
+	if !principal.IsModerator() {
+		return &Error{Code: CodeForbidden, Message: "only moderators can do this"}
+	}
+
+	return nil
+}
+
+func requireAdmin(ctx context.Context) error {
+	principal := auth.ForContext(ctx)
+	if principal == nil {
+		return auth.ErrUnauthenticated
+	}
+	if !principal.IsAdmin() {
+		return &Error{Code: CodeForbidden, Message: "only admins can do this"}
+	}
+
+	return nil
+}
@@ -0,0 +1,24 @@
+package service
+
+import (
+	"fmt"
+	"time"
+)
+
+type Config struct {
+	// MaxPageSize bounds first and last of every connection.
+	MaxPageSize int `env:"MAX_PAGE_SIZE" envDefault:"100"`
+	// ViewerCountInterval is how often the viewer counts of this instance
+	// are shared with the others.
+	ViewerCountInterval time.Duration `env:"VIEWER_COUNT_INTERVAL" envDefault:"10s"`
+}
+
+func (c Config) Validate() error {
+	if c.MaxPageSize < 1 {
+		return fmt.Errorf("max page size must be positive, got %d", c.MaxPageSize)
+	}
+	if c.ViewerCountInterval <= 0 {
+		return fmt.Errorf("viewer count interval must be positive, got %v", c.ViewerCountInterval)
+	}
+	return nil
+}
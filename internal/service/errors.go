@@ -0,0 +1,53 @@
+package service
+
+import (
+	"fmt"
+	"time"
+)
+
+type ErrorCode string
+
+const (
+	CodeNotFound         ErrorCode = "NOT_FOUND"
+	CodeCommentsDisabled ErrorCode = "COMMENTS_DISABLED"
+	CodeValidation       ErrorCode = "VALIDATION"
+	CodeInvalidCursor    ErrorCode = "INVALID_CURSOR"
+	CodeUnauthenticated  ErrorCode = "UNAUTHENTICATED"
+	CodeForbidden        ErrorCode = "FORBIDDEN"
+	CodePostLocked       ErrorCode = "POST_LOCKED"
+	CodeAuthorBanned     ErrorCode = "AUTHOR_BANNED"
+)
+
+// Error is an error the service reports to clients together with its code.
+// Repositories return Errors too, so clients see the same code whatever the
+// storage backend is.
+type Error struct {
+	Code    ErrorCode
+	Message string
+}
+
+func Errorf(code ErrorCode, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// AuthorBanned reports an author whose ban has not expired yet.
+func AuthorBanned(until time.Time) *Error {
+	return Errorf(CodeAuthorBanned, "the author is banned until %s", until.Format(time.RFC3339))
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Is matches any Error with the same code, so
+// errors.Is(err, &Error{Code: CodeNotFound}) holds for every not found error
+// regardless of its message.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+var (
+	ErrCommentsDisabled = &Error{Code: CodeCommentsDisabled, Message: "comments are disabled for the post"}
+	ErrPostLocked       = &Error{Code: CodePostLocked, Message: "the post is locked by a moderator"}
+)
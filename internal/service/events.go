@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/AEKDA/ozon_task/internal/api/graph/model"
+)
+
+const (
+	topicCommentAdded = "comment_added"
+	topicPostEvent    = "post_event"
+)
+
+const (
+	postCreated     = "created"
+	postUpdated     = "updated"
+	commentsToggled = "comments_toggled"
+	postDeleted     = "deleted"
+)
+
+// reloadTimeout bounds loading the state an event refers to, handlers are
+// called one at a time.
+const reloadTimeout = 5 * time.Second
+
+func (s *PostService) publish(ctx context.Context, topic string, event interface{}) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshal %s event %w", topic, err)
+	}
+
+	return s.events.Publish(ctx, topic, payload)
+}
+
+// Events carry ids only and the handlers load the current state from the
+// repository: pg_notify payloads are limited to 8000 bytes, which a post or a
+// comment with its parent can exceed.
+type commentAddedEvent struct {
+	CommentID int64  `json:"commentId"`
+	ParentID  *int64 `json:"parentId,omitempty"`
+}
+
+func (s *PostService) onCommentAdded(payload []byte) {
+	var event commentAddedEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), reloadTimeout)
+	defer cancel()
+
+	ids := []int64{event.CommentID}
+	if event.ParentID != nil {
+		ids = append(ids, *event.ParentID)
+	}
+	comments, err := s.commentRepo.GetCommentsByIDs(ctx, ids)
+	if err != nil {
+		return
+	}
+
+	comment, ok := comments[event.CommentID]
+	if !ok {
+		return
+	}
+	s.comments.Publish(comment.PostID, &comment)
+
+	if event.ParentID == nil {
+		return
+	}
+	parent, ok := comments[*event.ParentID]
+	if !ok {
+		return
+	}
+
+	s.replies.Publish(parent.ID, &comment)
+	if parent.AuthorID != comment.AuthorID {
+		s.notifications.Publish(parent.AuthorID, &model.ReplyNotification{
+			Comment: parent,
+			Reply:   comment,
+		})
+	}
+}
+
+// postEventMessage is the wire form of model.PostEvent, a union can not be
+// unmarshaled without knowing its member.
+type postEventMessage struct {
+	Kind   string `json:"kind"`
+	PostID int64  `json:"postId"`
+}
+
+func (s *PostService) onPostEvent(payload []byte) {
+	var message postEventMessage
+	if err := json.Unmarshal(payload, &message); err != nil {
+		return
+	}
+
+	if message.Kind == postDeleted {
+		s.postEvents.Publish(struct{}{}, model.PostDeleted{PostID: message.PostID})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), reloadTimeout)
+	defer cancel()
+
+	// A post deleted meanwhile is announced by its own event.
+	post, err := s.postRepo.GetPostByID(ctx, message.PostID)
+	if err != nil {
+		return
+	}
+
+	var event model.PostEvent
+	switch message.Kind {
+	case postCreated:
+		event = model.PostCreated{Post: *post}
+	case postUpdated:
+		event = model.PostUpdated{Post: *post}
+	case commentsToggled:
+		event = model.CommentsToggled{Post: *post, AllowComments: post.AllowComments}
+	default:
+		return
+	}
+
+	s.postEvents.Publish(struct{}{}, event)
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/AEKDA/ozon_task/internal/api/graph/model"
+)
+
+type ModerationRepository interface {
+	HideComment(ctx context.Context, commentID int64, hidden bool) (*model.Comment, error)
+	PinComment(ctx context.Context, commentID int64, pinned bool) (*model.Post, error)
+	LockPost(ctx context.Context, postID int64, locked bool) (*model.Post, error)
+	BanUser(ctx context.Context, userID int64, until time.Time) (*model.User, error)
+}
+
+type ModerationService struct {
+	moderationRepo ModerationRepository
+}
+
+func NewModerationService(moderation ModerationRepository) *ModerationService {
+	return &ModerationService{moderationRepo: moderation}
+}
+
+// HideComment withholds the content of the comment from everyone until it is
+// unhidden. Unlike a deleted comment the content is kept.
+func (s *ModerationService) HideComment(ctx context.Context, commentID int64, hidden bool) (*model.Comment, error) {
+	if err := requireModerator(ctx); err != nil {
+		return nil, err
+	}
+
+	return s.moderationRepo.HideComment(ctx, commentID, hidden)
+}
+
+// PinComment makes the comment the pinned comment of its post, replacing the
+// previously pinned one.
+func (s *ModerationService) PinComment(ctx context.Context, commentID int64, pinned bool) (*model.Post, error) {
+	if err := requireModerator(ctx); err != nil {
+		return nil, err
+	}
+
+	return s.moderationRepo.PinComment(ctx, commentID, pinned)
+}
+
+func (s *ModerationService) LockPost(ctx context.Context, postID int64, locked bool) (*model.Post, error) {
+	if err := requireModerator(ctx); err != nil {
+		return nil, err
+	}
+
+	return s.moderationRepo.LockPost(ctx, postID, locked)
+}
+
+// BanAuthor stops the user from writing posts and comments until the given
+// time. A time in the past lifts the ban.
+func (s *ModerationService) BanAuthor(ctx context.Context, userID int64, until time.Time) (*model.User, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	return s.moderationRepo.BanUser(ctx, userID, until)
+}
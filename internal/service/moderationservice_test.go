@@ -0,0 +1,146 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/AEKDA/ozon_task/internal/api/graph/model"
+	"github.com/AEKDA/ozon_task/internal/auth"
+	"github.com/AEKDA/ozon_task/internal/service"
+)
+
+func isCode(err error, code service.ErrorCode) bool {
+	return errors.Is(err, &service.Error{Code: code})
+}
+
+func TestLockPost(t *testing.T) {
+	s, db := newPostService(t)
+	moderation := service.NewModerationService(db)
+	alice := as(addUser(t, db, 1, "alice"), auth.RoleUser)
+	moderator := as(addUser(t, db, 2, "moderator"), auth.RoleModerator)
+
+	post, err := s.AddPost(alice, model.AddPostInput{Title: "title", Content: "content", AllowComments: true})
+	if err != nil {
+		t.Fatalf("AddPost: %v", err)
+	}
+	comment, err := s.AddCommentToPost(alice, model.AddCommentInput{PostID: post.ID, Content: "comment"})
+	if err != nil {
+		t.Fatalf("AddCommentToPost: %v", err)
+	}
+
+	if _, err := moderation.LockPost(alice, post.ID, true); !isCode(err, service.CodeForbidden) {
+		t.Errorf("LockPost by the author: got error %v, want %s", err, service.CodeForbidden)
+	}
+
+	locked, err := moderation.LockPost(moderator, post.ID, true)
+	if err != nil {
+		t.Fatalf("LockPost: %v", err)
+	}
+	if !locked.Locked {
+		t.Errorf("post is not locked")
+	}
+
+	if _, err := s.AddCommentToPost(alice, model.AddCommentInput{PostID: post.ID, Content: "comment"}); !errors.Is(err, service.ErrPostLocked) {
+		t.Errorf("AddCommentToPost on a locked post: got error %v, want %v", err, service.ErrPostLocked)
+	}
+	if _, err := s.AddReplyToComment(alice, model.AddReplyInput{CommentID: comment.ID, Content: "reply"}); !errors.Is(err, service.ErrPostLocked) {
+		t.Errorf("AddReplyToComment on a locked post: got error %v, want %v", err, service.ErrPostLocked)
+	}
+
+	if _, err := moderation.LockPost(moderator, post.ID, false); err != nil {
+		t.Fatalf("unlock: %v", err)
+	}
+	if _, err := s.AddCommentToPost(alice, model.AddCommentInput{PostID: post.ID, Content: "comment"}); err != nil {
+		t.Errorf("AddCommentToPost on an unlocked post: %v", err)
+	}
+}
+
+func TestBanAuthor(t *testing.T) {
+	s, db := newPostService(t)
+	moderation := service.NewModerationService(db)
+	aliceID := addUser(t, db, 1, "alice")
+	alice := as(aliceID, auth.RoleUser)
+	moderator := as(addUser(t, db, 2, "moderator"), auth.RoleModerator)
+	admin := as(addUser(t, db, 3, "admin"), auth.RoleAdmin)
+
+	post, err := s.AddPost(alice, model.AddPostInput{Title: "title", Content: "content", AllowComments: true})
+	if err != nil {
+		t.Fatalf("AddPost: %v", err)
+	}
+	comment, err := s.AddCommentToPost(alice, model.AddCommentInput{PostID: post.ID, Content: "comment"})
+	if err != nil {
+		t.Fatalf("AddCommentToPost: %v", err)
+	}
+
+	if _, err := moderation.BanAuthor(moderator, aliceID, time.Now().Add(time.Hour)); !isCode(err, service.CodeForbidden) {
+		t.Errorf("BanAuthor by a moderator: got error %v, want %s", err, service.CodeForbidden)
+	}
+
+	if _, err := moderation.BanAuthor(admin, aliceID, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("BanAuthor: %v", err)
+	}
+
+	if _, err := s.AddPost(alice, model.AddPostInput{Title: "title", Content: "content"}); !isCode(err, service.CodeAuthorBanned) {
+		t.Errorf("AddPost by a banned author: got error %v, want %s", err, service.CodeAuthorBanned)
+	}
+	if _, err := s.AddCommentToPost(alice, model.AddCommentInput{PostID: post.ID, Content: "comment"}); !isCode(err, service.CodeAuthorBanned) {
+		t.Errorf("AddCommentToPost by a banned author: got error %v, want %s", err, service.CodeAuthorBanned)
+	}
+	if _, err := s.AddReplyToComment(alice, model.AddReplyInput{CommentID: comment.ID, Content: "reply"}); !isCode(err, service.CodeAuthorBanned) {
+		t.Errorf("AddReplyToComment by a banned author: got error %v, want %s", err, service.CodeAuthorBanned)
+	}
+
+	// A ban in the past lifts it.
+	if _, err := moderation.BanAuthor(admin, aliceID, time.Now().Add(-time.Hour)); err != nil {
+		t.Fatalf("lift ban: %v", err)
+	}
+	if _, err := s.AddCommentToPost(alice, model.AddCommentInput{PostID: post.ID, Content: "comment"}); err != nil {
+		t.Errorf("AddCommentToPost after the ban: %v", err)
+	}
+}
+
+func TestHideComment(t *testing.T) {
+	s, db := newPostService(t)
+	moderation := service.NewModerationService(db)
+	alice := as(addUser(t, db, 1, "alice"), auth.RoleUser)
+	moderator := as(addUser(t, db, 2, "moderator"), auth.RoleModerator)
+
+	post, err := s.AddPost(alice, model.AddPostInput{Title: "title", Content: "content", AllowComments: true})
+	if err != nil {
+		t.Fatalf("AddPost: %v", err)
+	}
+	comment, err := s.AddCommentToPost(alice, model.AddCommentInput{PostID: post.ID, Content: "spam"})
+	if err != nil {
+		t.Fatalf("AddCommentToPost: %v", err)
+	}
+
+	if _, err := moderation.HideComment(alice, comment.ID, true); !isCode(err, service.CodeForbidden) {
+		t.Errorf("HideComment by the author: got error %v, want %s", err, service.CodeForbidden)
+	}
+
+	content := func() (string, bool) {
+		t.Helper()
+
+		comments, err := db.GetCommentsByIDs(context.Background(), []int64{comment.ID})
+		if err != nil {
+			t.Fatalf("GetCommentsByIDs: %v", err)
+		}
+		return comments[comment.ID].Content, comments[comment.ID].IsHidden
+	}
+
+	if _, err := moderation.HideComment(moderator, comment.ID, true); err != nil {
+		t.Fatalf("HideComment: %v", err)
+	}
+	if got, hidden := content(); got != "" || !hidden {
+		t.Errorf("hidden comment: got content %q hidden=%t, want no content and hidden", got, hidden)
+	}
+
+	if _, err := moderation.HideComment(moderator, comment.ID, false); err != nil {
+		t.Fatalf("unhide: %v", err)
+	}
+	if got, hidden := content(); got != "spam" || hidden {
+		t.Errorf("unhidden comment: got content %q hidden=%t, want %q and not hidden", got, hidden, "spam")
+	}
+}
@@ -2,91 +2,421 @@ package service
 
 import (
 	"context"
-	"sync"
 
 	"github.com/AEKDA/ozon_task/internal/api/graph/model"
+	"github.com/AEKDA/ozon_task/internal/auth"
+	"github.com/AEKDA/ozon_task/internal/pubsub"
+	"github.com/AEKDA/ozon_task/internal/repository/cursor"
 )
 
 type PostRepository interface {
-	AddPost(ctx context.Context, post model.AddPostInput) (*model.Post, error)
+	AddPost(ctx context.Context, authorID int64, post model.AddPostInput) (*model.Post, error)
 	GetPostByID(ctx context.Context, id int64) (*model.Post, error)
-	GetPosts(ctx context.Context, first int, after *string) (*model.PostConnection, error)
+	GetPosts(ctx context.Context, page cursor.Page, order model.PostOrder, filter *model.PostFilter) (*model.PostConnection, error)
 	SetCommentPremission(ctx context.Context, postID int64, allow bool) (*model.Post, error)
+	UpdatePost(ctx context.Context, editorID int64, input model.UpdatePostInput) (*model.Post, error)
+	DeletePost(ctx context.Context, postID int64) error
 }
 
+// CommentRepository returns empty connections for posts and comments that do
+// not exist, so a missing id does not fail the rest of a dataloader batch.
 type CommentRepository interface {
-	AddCommentToPost(ctx context.Context, commentInput model.AddCommentInput) (*model.Comment, error)
-	AddReplyToComment(ctx context.Context, commentInput model.AddReplyInput) (*model.Comment, error)
-	GetCommentsByPostID(ctx context.Context, postID int64, first int, after *string) (model.CommentConnection, error)
-	GetCommentsByPostIDs(ctx context.Context, postID []int64, first int, after *string) (map[int64]model.CommentConnection, error)
+	AddCommentToPost(ctx context.Context, authorID int64, commentInput model.AddCommentInput) (*model.Comment, error)
+	AddReplyToComment(ctx context.Context, authorID int64, commentInput model.AddReplyInput) (*model.Comment, error)
+	GetCommentsByPostID(ctx context.Context, postID int64, page cursor.Page, rootsOnly bool) (model.CommentConnection, error)
+	GetCommentsByPostIDs(ctx context.Context, postID []int64, page cursor.Page, rootsOnly bool) (map[int64]model.CommentConnection, error)
+	GetCommentsByIDs(ctx context.Context, ids []int64) (map[int64]model.Comment, error)
+	GetRepliesByCommentIDs(ctx context.Context, commentIDs []int64, page cursor.Page) (map[int64]model.CommentConnection, error)
+	GetCommentDepths(ctx context.Context, ids []int64) (map[int64]int, error)
+	CountReplies(ctx context.Context, commentIDs []int64) (map[int64]int, error)
+	EditComment(ctx context.Context, editorID int64, input model.EditCommentInput) (*model.Comment, error)
+	DeleteComment(ctx context.Context, commentID int64) (*model.Comment, error)
+}
+
+type RevisionRepository interface {
+	GetPostRevisions(ctx context.Context, postIDs []int64, page cursor.Page) (map[int64]model.PostRevisionConnection, error)
+	GetCommentRevisions(ctx context.Context, commentIDs []int64, page cursor.Page) (map[int64]model.CommentRevisionConnection, error)
+}
+
+type SearchRepository interface {
+	Search(ctx context.Context, query string, page cursor.Page) (*model.SearchConnection, error)
 }
 
 type PostService struct {
+	cfg           Config
+	subscriptions pubsub.Config
+
 	postRepo    PostRepository
 	commentRepo CommentRepository
+	searchRepo  SearchRepository
 
-	mu          sync.Mutex
-	subscribers map[int64][]chan *model.Comment
+	events        pubsub.Bus
+	comments      *pubsub.Hub[int64, *model.Comment]
+	replies       *pubsub.Hub[int64, *model.Comment]
+	notifications *pubsub.Hub[int64, *model.ReplyNotification]
+	postEvents    *pubsub.Hub[struct{}, model.PostEvent]
+	viewers       *pubsub.Hub[int64, int]
+	viewerCounts  *viewerCounts
 }
 
-func NewPostService(post PostRepository, comment CommentRepository) *PostService {
-	return &PostService{
+func NewPostService(post PostRepository, comment CommentRepository, search SearchRepository, events pubsub.Bus, subscriptions pubsub.Config, cfg Config) *PostService {
+	s := &PostService{
+		cfg:           cfg,
+		subscriptions: subscriptions,
+
 		postRepo:    post,
 		commentRepo: comment,
+		searchRepo:  search,
 
-		subscribers: make(map[int64][]chan *model.Comment),
+		events:        events,
+		comments:      pubsub.NewHub[int64, *model.Comment](subscriptions),
+		replies:       pubsub.NewHub[int64, *model.Comment](subscriptions),
+		notifications: pubsub.NewHub[int64, *model.ReplyNotification](subscriptions),
+		postEvents:    pubsub.NewHub[struct{}, model.PostEvent](subscriptions),
+		viewers:       pubsub.NewHub[int64, int](subscriptions),
+		viewerCounts:  newViewerCounts(),
 	}
+	s.comments.OnCountChange(s.onLocalCount)
+	events.Subscribe(topicCommentAdded, s.onCommentAdded)
+	events.Subscribe(topicPostEvent, s.onPostEvent)
+	events.Subscribe(topicViewerCount, s.onViewerCount)
+
+	return s
 }
 
-func (s *PostService) SubscriptionOnPost(ctx context.Context, postID int64) (<-chan *model.Comment, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// SubscriptionOnPost streams the comments added to the post until ctx is done.
+// With afterCursor set, the comments stored after the cursor are replayed
+// first, so a reconnecting client does not miss the ones added meanwhile.
+func (s *PostService) SubscriptionOnPost(ctx context.Context, postID int64, afterCursor *string) (<-chan *model.Comment, error) {
+	if _, err := s.postRepo.GetPostByID(ctx, postID); err != nil {
+		return nil, err
+	}
+
+	if afterCursor == nil {
+		return s.comments.Subscribe(ctx, postID), nil
+	}
+
+	// Subscribing before reading the repository leaves no window in which a
+	// new comment is neither replayed nor delivered live.
+	subCtx, cancel := context.WithCancel(ctx)
+	live := s.comments.Subscribe(subCtx, postID)
+
+	page, err := s.replayPage(subCtx, postID, *afterCursor)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	out := make(chan *model.Comment)
+	go func() {
+		defer cancel()
+		defer close(out)
+		s.replay(subCtx, postID, page, live, out)
+	}()
 
-	commentChan := make(chan *model.Comment, 1)
-	s.subscribers[postID] = append(s.subscribers[postID], commentChan)
+	return out, nil
+}
 
-	return commentChan, nil
+func (s *PostService) replayPage(ctx context.Context, postID int64, after string) (model.CommentConnection, error) {
+	return s.commentRepo.GetCommentsByPostID(ctx, postID, cursor.Page{Limit: cursor.DefaultPageSize, After: after}, false)
 }
 
-func (r *PostService) NotifySubscribers(postID int64, comment *model.Comment) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
+// replay sends the stored comments page by page starting with page, then
+// forwards the live ones. Live comments arriving during the replay are
+// buffered here, as many as the hub would queue and under the same overflow
+// policy. Ids are not committed in order, so a live comment is only dropped
+// if that very comment was replayed.
+func (s *PostService) replay(ctx context.Context, postID int64, page model.CommentConnection, live <-chan *model.Comment, out chan<- *model.Comment) {
+	var buffered []*model.Comment
+	replayed := make(map[int64]struct{})
+
+	// forward sends comment to out, buffering the live comments meanwhile.
+	forward := func(comment *model.Comment) bool {
+		for {
+			select {
+			case out <- comment:
+				return true
+			case c, ok := <-live:
+				if !ok {
+					return false
+				}
+				if len(buffered) >= s.subscriptions.BufferSize {
+					if s.subscriptions.Overflow == pubsub.Disconnect {
+						return false
+					}
+					buffered[0] = nil
+					buffered = buffered[1:]
+				}
+				buffered = append(buffered, c)
+			case <-ctx.Done():
+				return false
+			}
+		}
+	}
+
+	// sent reports whether comment was replayed, forgetting it as it can only
+	// be delivered live once.
+	sent := func(comment *model.Comment) bool {
+		if _, ok := replayed[comment.ID]; ok {
+			delete(replayed, comment.ID)
+			return true
+		}
+		return false
+	}
+
+	for {
+		for _, edge := range page.Edges {
+			comment := edge.Node
+			if !forward(&comment) {
+				return
+			}
+			replayed[comment.ID] = struct{}{}
+		}
+
+		if !page.PageInfo.HasNextPage {
+			break
+		}
 
-	if chans, found := r.subscribers[postID]; found {
-		for _, ch := range chans {
+		var err error
+		if page, err = s.replayPage(ctx, postID, page.PageInfo.EndCursor); err != nil {
+			return
+		}
+	}
 
-			ch <- comment
+	for len(buffered) > 0 {
+		comment := buffered[0]
+		buffered[0] = nil
+		buffered = buffered[1:]
+		if sent(comment) {
+			continue
+		}
+		if !forward(comment) {
+			return
 		}
 	}
+
+	for comment := range live {
+		if sent(comment) {
+			continue
+		}
+
+		select {
+		case out <- comment:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// SubscriptionOnReplies streams the replies added to the comment until ctx is
+// done.
+func (s *PostService) SubscriptionOnReplies(ctx context.Context, commentID int64) (<-chan *model.Comment, error) {
+	comments, err := s.commentRepo.GetCommentsByIDs(ctx, []int64{commentID})
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := comments[commentID]; !ok {
+		return nil, Errorf(CodeNotFound, "comment %d not found", commentID)
+	}
+
+	return s.replies.Subscribe(ctx, commentID), nil
+}
+
+// SubscriptionOnNotifications streams the replies other users leave on the
+// caller's comments until ctx is done.
+func (s *PostService) SubscriptionOnNotifications(ctx context.Context) (<-chan *model.ReplyNotification, error) {
+	principal := auth.ForContext(ctx)
+	if principal == nil {
+		return nil, auth.ErrUnauthenticated
+	}
+
+	return s.notifications.Subscribe(ctx, principal.UserID), nil
+}
+
+// SubscriptionOnPostEvents streams the posts being created, updated and
+// deleted until ctx is done.
+func (s *PostService) SubscriptionOnPostEvents(ctx context.Context) (<-chan model.PostEvent, error) {
+	return s.postEvents.Subscribe(ctx, struct{}{}), nil
+}
+
+// ViewerCount returns the number of commentAdded subscribers of the post
+// connected to any instance. The counts of other instances are as recent as
+// the last one they shared, see ShareViewerCounts.
+func (s *PostService) ViewerCount(postID int64) int {
+	s.viewerCounts.mu.Lock()
+	defer s.viewerCounts.mu.Unlock()
+
+	return s.viewerCounts.total(postID)
+}
+
+// SubscriptionOnViewerCount streams the viewer count of the post, starting
+// with the current one, until ctx is done.
+func (s *PostService) SubscriptionOnViewerCount(ctx context.Context, postID int64) (<-chan int, error) {
+	if _, err := s.postRepo.GetPostByID(ctx, postID); err != nil {
+		return nil, err
+	}
+
+	changes := s.viewers.Subscribe(ctx, postID)
+
+	out := make(chan int, 1)
+	out <- s.ViewerCount(postID)
+	go func() {
+		defer close(out)
+		for count := range changes {
+			select {
+			case out <- count:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// NotifySubscribers publishes the comment on the event bus, so subscribers
+// connected to any instance receive it, and the author of the comment it
+// replies to is notified.
+func (s *PostService) NotifySubscribers(ctx context.Context, comment *model.Comment) error {
+	return s.publish(ctx, topicCommentAdded, commentAddedEvent{CommentID: comment.ID, ParentID: comment.ReplyTo})
 }
 
 func (s *PostService) AddPost(ctx context.Context, input model.AddPostInput) (*model.Post, error) {
-	return s.postRepo.AddPost(ctx, input)
+	principal := auth.ForContext(ctx)
+	if principal == nil {
+		return nil, auth.ErrUnauthenticated
+	}
+
+	post, err := s.postRepo.AddPost(ctx, principal.UserID, input)
+	if err != nil {
+		return nil, err
+	}
+
+	_ = s.publish(ctx, topicPostEvent, postEventMessage{Kind: postCreated, PostID: post.ID})
+
+	return post, nil
 }
 
 func (s *PostService) AddCommentToPost(ctx context.Context, input model.AddCommentInput) (*model.Comment, error) {
+	principal := auth.ForContext(ctx)
+	if principal == nil {
+		return nil, auth.ErrUnauthenticated
+	}
 
-	comment, err := s.commentRepo.AddCommentToPost(ctx, input)
+	comment, err := s.commentRepo.AddCommentToPost(ctx, principal.UserID, input)
+	if err != nil {
+		return nil, err
+	}
 
-	s.NotifySubscribers(input.PostID, comment)
+	// The comment is stored already, a lost notification must not fail the
+	// mutation. The bus logs the failure.
+	_ = s.NotifySubscribers(ctx, comment)
 
-	return comment, err
+	return comment, nil
 }
 
 func (s *PostService) AddReplyToComment(ctx context.Context, input model.AddReplyInput) (*model.Comment, error) {
-	return s.commentRepo.AddReplyToComment(ctx, input)
+	principal := auth.ForContext(ctx)
+	if principal == nil {
+		return nil, auth.ErrUnauthenticated
+	}
+
+	reply, err := s.commentRepo.AddReplyToComment(ctx, principal.UserID, input)
+	if err != nil {
+		return nil, err
+	}
+
+	_ = s.NotifySubscribers(ctx, reply)
+
+	return reply, nil
 }
 
 func (s *PostService) SetCommentPremission(ctx context.Context, postID int64, allow bool) (*model.Post, error) {
-	return s.postRepo.SetCommentPremission(ctx, postID, allow)
+	if err := s.authorizePost(ctx, postID); err != nil {
+		return nil, err
+	}
+
+	post, err := s.postRepo.SetCommentPremission(ctx, postID, allow)
+	if err != nil {
+		return nil, err
+	}
+
+	_ = s.publish(ctx, topicPostEvent, postEventMessage{Kind: commentsToggled, PostID: post.ID})
+
+	return post, nil
 }
 
-func (s *PostService) Posts(ctx context.Context, first int, after *string) (*model.PostConnection, error) {
-	return s.postRepo.GetPosts(ctx, first, after)
+func (s *PostService) UpdatePost(ctx context.Context, input model.UpdatePostInput) (*model.Post, error) {
+	if err := s.authorizePost(ctx, input.ID); err != nil {
+		return nil, err
+	}
+
+	// The revision is written by whoever edits the post, which may be a
+	// moderator rather than its author.
+	post, err := s.postRepo.UpdatePost(ctx, auth.ForContext(ctx).UserID, input)
+	if err != nil {
+		return nil, err
+	}
+
+	_ = s.publish(ctx, topicPostEvent, postEventMessage{Kind: postUpdated, PostID: post.ID})
+
+	return post, nil
+}
+
+func (s *PostService) DeletePost(ctx context.Context, postID int64) error {
+	if err := s.authorizePost(ctx, postID); err != nil {
+		return err
+	}
+
+	if err := s.postRepo.DeletePost(ctx, postID); err != nil {
+		return err
+	}
+
+	_ = s.publish(ctx, topicPostEvent, postEventMessage{Kind: postDeleted, PostID: postID})
+
+	return nil
 }
 
-func (s *PostService) Comments(ctx context.Context, postID int64, first int, after *string) (*model.CommentConnection, error) {
-	comments, err := s.commentRepo.GetCommentsByPostID(ctx, postID, first, after)
+func (s *PostService) EditComment(ctx context.Context, input model.EditCommentInput) (*model.Comment, error) {
+	if err := s.authorizeComment(ctx, input.ID); err != nil {
+		return nil, err
+	}
+
+	return s.commentRepo.EditComment(ctx, auth.ForContext(ctx).UserID, input)
+}
+
+func (s *PostService) DeleteComment(ctx context.Context, commentID int64) (*model.Comment, error) {
+	if err := s.authorizeComment(ctx, commentID); err != nil {
+		return nil, err
+	}
+
+	return s.commentRepo.DeleteComment(ctx, commentID)
+}
+
+// Page builds the page of connection arguments, rejecting pages larger than
+// the configured maximum.
+func (s *PostService) Page(first *int, after *string, last *int, before *string) (cursor.Page, error) {
+	page, err := cursor.NewPage(first, after, last, before)
+	if err != nil {
+		return cursor.Page{}, err
+	}
+	if page.Limit > s.cfg.MaxPageSize {
+		return cursor.Page{}, Errorf(CodeValidation, "page size %d exceeds the maximum of %d", page.Limit, s.cfg.MaxPageSize)
+	}
+
+	return page, nil
+}
+
+func (s *PostService) Posts(ctx context.Context, page cursor.Page, order model.PostOrder, filter *model.PostFilter) (*model.PostConnection, error) {
+	return s.postRepo.GetPosts(ctx, page, order, filter)
+}
+
+func (s *PostService) Comments(ctx context.Context, postID int64, page cursor.Page, rootsOnly bool) (*model.CommentConnection, error) {
+	if _, err := s.postRepo.GetPostByID(ctx, postID); err != nil {
+		return nil, err
+	}
+
+	comments, err := s.commentRepo.GetCommentsByPostID(ctx, postID, page, rootsOnly)
 	if err != nil {
 		return nil, err
 	}
@@ -97,3 +427,7 @@ func (s *PostService) Comments(ctx context.Context, postID int64, first int, aft
 func (s *PostService) Post(ctx context.Context, id int64) (*model.Post, error) {
 	return s.postRepo.GetPostByID(ctx, id)
 }
+
+func (s *PostService) Search(ctx context.Context, query string, page cursor.Page) (*model.SearchConnection, error) {
+	return s.searchRepo.Search(ctx, query, page)
+}
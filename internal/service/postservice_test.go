@@ -0,0 +1,402 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/AEKDA/ozon_task/internal/api/graph/model"
+	"github.com/AEKDA/ozon_task/internal/auth"
+	"github.com/AEKDA/ozon_task/internal/pubsub"
+	"github.com/AEKDA/ozon_task/internal/repository/cursor"
+	"github.com/AEKDA/ozon_task/internal/repository/inmemory"
+	"github.com/AEKDA/ozon_task/internal/service"
+)
+
+func newPostService(t *testing.T) (*service.PostService, *inmemory.InMemoryDB) {
+	t.Helper()
+
+	db := inmemory.NewInMemoryDB()
+	s := service.NewPostService(db, db, db, pubsub.NewLocalBus(),
+		pubsub.Config{BufferSize: 16, Overflow: pubsub.DropOldest},
+		service.Config{MaxPageSize: 100})
+
+	return s, db
+}
+
+func addUser(t *testing.T, db *inmemory.InMemoryDB, id int64, name string) int64 {
+	t.Helper()
+
+	user, err := db.AddUser(context.Background(), id, model.RegisterUserInput{Name: name})
+	if err != nil {
+		t.Fatalf("add user %s: %v", name, err)
+	}
+	return user.ID
+}
+
+func as(userID int64, role auth.Role) context.Context {
+	return auth.WithPrincipal(context.Background(), &auth.Principal{UserID: userID, Role: role})
+}
+
+// The author of new content is the caller, clients cannot name another one.
+func TestAuthorIsTheCaller(t *testing.T) {
+	s, db := newPostService(t)
+	addUser(t, db, 1, "someone")
+	alice := addUser(t, db, 2, "alice")
+	ctx := as(alice, auth.RoleUser)
+
+	post, err := s.AddPost(ctx, model.AddPostInput{Title: "title", Content: "content", AllowComments: true})
+	if err != nil {
+		t.Fatalf("AddPost: %v", err)
+	}
+	if post.AuthorID != alice {
+		t.Errorf("post author is %d, want %d", post.AuthorID, alice)
+	}
+
+	comment, err := s.AddCommentToPost(ctx, model.AddCommentInput{PostID: post.ID, Content: "comment"})
+	if err != nil {
+		t.Fatalf("AddCommentToPost: %v", err)
+	}
+	if comment.AuthorID != alice {
+		t.Errorf("comment author is %d, want %d", comment.AuthorID, alice)
+	}
+
+	reply, err := s.AddReplyToComment(ctx, model.AddReplyInput{CommentID: comment.ID, Content: "reply"})
+	if err != nil {
+		t.Fatalf("AddReplyToComment: %v", err)
+	}
+	if reply.AuthorID != alice {
+		t.Errorf("reply author is %d, want %d", reply.AuthorID, alice)
+	}
+}
+
+// A registered user is the subject of the caller's token, so later tokens
+// with that subject act as the user.
+func TestRegisterUserIsTheCaller(t *testing.T) {
+	db := inmemory.NewInMemoryDB()
+	users := service.NewUserService(db)
+
+	if _, err := users.RegisterUser(context.Background(), model.RegisterUserInput{Name: "alice"}); !errors.Is(err, auth.ErrUnauthenticated) {
+		t.Errorf("anonymous RegisterUser: got error %v, want %v", err, auth.ErrUnauthenticated)
+	}
+
+	user, err := users.RegisterUser(as(42, auth.RoleUser), model.RegisterUserInput{Name: "alice"})
+	if err != nil {
+		t.Fatalf("RegisterUser: %v", err)
+	}
+	if user.ID != 42 {
+		t.Errorf("user id is %d, want the token subject 42", user.ID)
+	}
+
+	if _, err := users.RegisterUser(as(42, auth.RoleUser), model.RegisterUserInput{Name: "bob"}); !errors.Is(err, &service.Error{Code: service.CodeValidation}) {
+		t.Errorf("second RegisterUser: got error %v, want a %s error", err, service.CodeValidation)
+	}
+}
+
+func TestAnonymousCannotWrite(t *testing.T) {
+	s, db := newPostService(t)
+	alice := addUser(t, db, 1, "alice")
+
+	post, err := s.AddPost(as(alice, auth.RoleUser), model.AddPostInput{Title: "title", Content: "content", AllowComments: true})
+	if err != nil {
+		t.Fatalf("AddPost: %v", err)
+	}
+
+	ctx := context.Background()
+	if _, err := s.AddPost(ctx, model.AddPostInput{Title: "title", Content: "content"}); !errors.Is(err, auth.ErrUnauthenticated) {
+		t.Errorf("AddPost: got error %v, want %v", err, auth.ErrUnauthenticated)
+	}
+	if _, err := s.AddCommentToPost(ctx, model.AddCommentInput{PostID: post.ID, Content: "comment"}); !errors.Is(err, auth.ErrUnauthenticated) {
+		t.Errorf("AddCommentToPost: got error %v, want %v", err, auth.ErrUnauthenticated)
+	}
+}
+
+// Revisions are attributed to whoever wrote them, so a moderator's edit of
+// someone else's content is recorded as the moderator's.
+func TestRevisionAuthorIsTheEditor(t *testing.T) {
+	s, db := newPostService(t)
+	alice := addUser(t, db, 1, "alice")
+	moderator := addUser(t, db, 2, "moderator")
+
+	post, err := s.AddPost(as(alice, auth.RoleUser), model.AddPostInput{Title: "title", Content: "content", AllowComments: true})
+	if err != nil {
+		t.Fatalf("AddPost: %v", err)
+	}
+	comment, err := s.AddCommentToPost(as(alice, auth.RoleUser), model.AddCommentInput{PostID: post.ID, Content: "comment"})
+	if err != nil {
+		t.Fatalf("AddCommentToPost: %v", err)
+	}
+
+	content := "edited by a moderator"
+	ctx := as(moderator, auth.RoleModerator)
+	if _, err := s.UpdatePost(ctx, model.UpdatePostInput{ID: post.ID, Content: &content}); err != nil {
+		t.Fatalf("UpdatePost: %v", err)
+	}
+	if _, err := s.EditComment(ctx, model.EditCommentInput{ID: comment.ID, Content: content}); err != nil {
+		t.Fatalf("EditComment: %v", err)
+	}
+
+	page := cursor.Page{Limit: 10}
+	postRevisions, err := db.GetPostRevisions(ctx, []int64{post.ID}, page)
+	if err != nil {
+		t.Fatalf("GetPostRevisions: %v", err)
+	}
+	commentRevisions, err := db.GetCommentRevisions(ctx, []int64{comment.ID}, page)
+	if err != nil {
+		t.Fatalf("GetCommentRevisions: %v", err)
+	}
+
+	var postAuthors, commentAuthors []int64
+	for _, edge := range postRevisions[post.ID].Edges {
+		postAuthors = append(postAuthors, edge.Node.AuthorID)
+	}
+	for _, edge := range commentRevisions[comment.ID].Edges {
+		commentAuthors = append(commentAuthors, edge.Node.AuthorID)
+	}
+
+	want := []int64{alice, moderator}
+	if !slices.Equal(postAuthors, want) {
+		t.Errorf("post revision authors are %v, want %v", postAuthors, want)
+	}
+	if !slices.Equal(commentAuthors, want) {
+		t.Errorf("comment revision authors are %v, want %v", commentAuthors, want)
+	}
+
+	// The content itself still belongs to its author.
+	edited, err := db.GetPostByID(ctx, post.ID)
+	if err != nil {
+		t.Fatalf("GetPostByID: %v", err)
+	}
+	if edited.AuthorID != alice {
+		t.Errorf("post author is %d after the edit, want %d", edited.AuthorID, alice)
+	}
+}
+
+// A subscription resuming after a cursor replays the stored comments once and
+// then continues with the live ones, including those added during the replay.
+func TestSubscriptionReplay(t *testing.T) {
+	s, db := newPostService(t)
+	alice := addUser(t, db, 1, "alice")
+	ctx, cancel := context.WithCancel(as(alice, auth.RoleUser))
+	defer cancel()
+
+	post, err := s.AddPost(ctx, model.AddPostInput{Title: "title", Content: "content", AllowComments: true})
+	if err != nil {
+		t.Fatalf("AddPost: %v", err)
+	}
+
+	addComment := func(content string) *model.Comment {
+		t.Helper()
+
+		comment, err := s.AddCommentToPost(ctx, model.AddCommentInput{PostID: post.ID, Content: content})
+		if err != nil {
+			t.Fatalf("AddCommentToPost: %v", err)
+		}
+		return comment
+	}
+
+	first := addComment("c1")
+	replayed := addComment("c2")
+	addComment("c3")
+
+	after := cursor.Encode(first.ID)
+	comments, err := s.SubscriptionOnPost(ctx, post.ID, &after)
+	if err != nil {
+		t.Fatalf("SubscriptionOnPost: %v", err)
+	}
+
+	// Nothing is read yet, so these arrive while c2 is being replayed. A
+	// repeated event for a replayed comment is not sent twice.
+	addComment("c4")
+	if err := s.NotifySubscribers(ctx, replayed); err != nil {
+		t.Fatalf("NotifySubscribers: %v", err)
+	}
+	addComment("c5")
+
+	receive := func() string {
+		t.Helper()
+
+		select {
+		case comment, ok := <-comments:
+			if !ok {
+				t.Fatal("subscription ended")
+			}
+			return comment.Content
+		case <-time.After(5 * time.Second):
+			t.Fatal("no comment received")
+		}
+		return ""
+	}
+
+	var got []string
+	for i := 0; i < 4; i++ {
+		got = append(got, receive())
+	}
+	addComment("c6")
+	got = append(got, receive())
+
+	if want := []string{"c2", "c3", "c4", "c5", "c6"}; !slices.Equal(got, want) {
+		t.Errorf("received %v, want %v", got, want)
+	}
+
+	select {
+	case comment := <-comments:
+		t.Errorf("received unexpected comment %q", comment.Content)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// uncommitted hides a comment from the pages, as if its insert had taken the
+// id but not committed yet.
+type uncommitted struct {
+	*inmemory.InMemoryDB
+	id int64
+}
+
+func (u uncommitted) GetCommentsByPostID(ctx context.Context, postID int64, page cursor.Page, rootsOnly bool) (model.CommentConnection, error) {
+	connection, err := u.InMemoryDB.GetCommentsByPostID(ctx, postID, page, rootsOnly)
+	connection.Edges = slices.DeleteFunc(connection.Edges, func(edge model.CommentEdge) bool {
+		return edge.Node.ID == u.id
+	})
+	return connection, err
+}
+
+// A comment committed after a higher id was replayed is still delivered.
+func TestSubscriptionReplayLateCommit(t *testing.T) {
+	db := inmemory.NewInMemoryDB()
+	alice := addUser(t, db, 1, "alice")
+	ctx, cancel := context.WithCancel(as(alice, auth.RoleUser))
+	defer cancel()
+
+	post, err := db.AddPost(ctx, alice, model.AddPostInput{Title: "title", Content: "content", AllowComments: true})
+	if err != nil {
+		t.Fatalf("AddPost: %v", err)
+	}
+
+	var comments []*model.Comment
+	for _, content := range []string{"c1", "c2", "c3"} {
+		comment, err := db.AddCommentToPost(ctx, alice, model.AddCommentInput{PostID: post.ID, Content: content})
+		if err != nil {
+			t.Fatalf("AddCommentToPost: %v", err)
+		}
+		comments = append(comments, comment)
+	}
+	first, late, replayed := comments[0], comments[1], comments[2]
+
+	s := service.NewPostService(db, uncommitted{InMemoryDB: db, id: late.ID}, db, pubsub.NewLocalBus(),
+		pubsub.Config{BufferSize: 16, Overflow: pubsub.DropOldest},
+		service.Config{MaxPageSize: 100})
+
+	after := cursor.Encode(first.ID)
+	ch, err := s.SubscriptionOnPost(ctx, post.ID, &after)
+	if err != nil {
+		t.Fatalf("SubscriptionOnPost: %v", err)
+	}
+
+	receive := func() string {
+		t.Helper()
+
+		select {
+		case comment, ok := <-ch:
+			if !ok {
+				t.Fatal("subscription ended")
+			}
+			return comment.Content
+		case <-time.After(5 * time.Second):
+			t.Fatal("no comment received")
+		}
+		return ""
+	}
+
+	if got := receive(); got != replayed.Content {
+		t.Fatalf("replayed %q, want %q", got, replayed.Content)
+	}
+
+	// The live event of the replayed comment is dropped, the one of the
+	// lower id committed afterwards is not.
+	for _, comment := range []*model.Comment{replayed, late} {
+		if err := s.NotifySubscribers(ctx, comment); err != nil {
+			t.Fatalf("NotifySubscribers: %v", err)
+		}
+	}
+	if got := receive(); got != late.Content {
+		t.Errorf("received %q, want the late comment %q", got, late.Content)
+	}
+
+	select {
+	case comment := <-ch:
+		t.Errorf("received unexpected comment %q", comment.Content)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// Viewer counts add up the subscribers connected to every instance, and the
+// viewers of an instance that stops sharing its counts expire.
+func TestViewerCountAcrossInstances(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db := inmemory.NewInMemoryDB()
+	bus := pubsub.NewLocalBus()
+	newInstance := func(ctx context.Context) *service.PostService {
+		s := service.NewPostService(db, db, db, bus,
+			pubsub.Config{BufferSize: 16, Overflow: pubsub.DropOldest},
+			service.Config{MaxPageSize: 100, ViewerCountInterval: 20 * time.Millisecond})
+		go s.ShareViewerCounts(ctx)
+		return s
+	}
+
+	sharingCtx, stopSharing := context.WithCancel(ctx)
+	first := newInstance(sharingCtx)
+	second := newInstance(ctx)
+
+	alice := addUser(t, db, 1, "alice")
+	post, err := first.AddPost(as(alice, auth.RoleUser), model.AddPostInput{Title: "title", Content: "content", AllowComments: true})
+	if err != nil {
+		t.Fatalf("AddPost: %v", err)
+	}
+
+	counts, err := second.SubscriptionOnViewerCount(ctx, post.ID)
+	if err != nil {
+		t.Fatalf("SubscriptionOnViewerCount: %v", err)
+	}
+	waitForCount := func(want int) {
+		t.Helper()
+
+		deadline := time.After(5 * time.Second)
+		for {
+			select {
+			case count := <-counts:
+				if count == want {
+					return
+				}
+			case <-deadline:
+				t.Fatalf("viewer count did not become %d", want)
+			}
+		}
+	}
+	waitForCount(0)
+
+	if _, err := first.SubscriptionOnPost(ctx, post.ID, nil); err != nil {
+		t.Fatalf("SubscriptionOnPost: %v", err)
+	}
+	waitForCount(1)
+
+	viewerCtx, leave := context.WithCancel(ctx)
+	if _, err := second.SubscriptionOnPost(viewerCtx, post.ID, nil); err != nil {
+		t.Fatalf("SubscriptionOnPost: %v", err)
+	}
+	waitForCount(2)
+	if count := second.ViewerCount(post.ID); count != 2 {
+		t.Errorf("ViewerCount is %d, want 2", count)
+	}
+
+	leave()
+	waitForCount(1)
+
+	// The first instance goes quiet, so its viewer expires on the second.
+	stopSharing()
+	waitForCount(0)
+}
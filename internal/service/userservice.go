@@ -0,0 +1,32 @@
+package service
+
+import (
+	"context"
+
+	"github.com/AEKDA/ozon_task/internal/api/graph/model"
+	"github.com/AEKDA/ozon_task/internal/auth"
+)
+
+type UserRepository interface {
+	AddUser(ctx context.Context, id int64, input model.RegisterUserInput) (*model.User, error)
+	GetUsersByIDs(ctx context.Context, ids []int64) (map[int64]model.User, error)
+}
+
+type UserService struct {
+	userRepo UserRepository
+}
+
+func NewUserService(user UserRepository) *UserService {
+	return &UserService{userRepo: user}
+}
+
+// RegisterUser creates the user the caller's token names, so the id of the
+// account is always the subject later tokens are verified against.
+func (s *UserService) RegisterUser(ctx context.Context, input model.RegisterUserInput) (*model.User, error) {
+	principal := auth.ForContext(ctx)
+	if principal == nil {
+		return nil, auth.ErrUnauthenticated
+	}
+
+	return s.userRepo.AddUser(ctx, principal.UserID, input)
+}
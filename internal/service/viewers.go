@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+const topicViewerCount = "viewer_count"
+
+// viewerShareTTL is how many sharing intervals a share of another instance is
+// counted without being refreshed, so the viewers of an instance that went
+// away are eventually dropped.
+const viewerShareTTL = 3
+
+// viewerCountEvent is the number of commentAdded subscribers of a post
+// connected to one instance.
+type viewerCountEvent struct {
+	Instance string `json:"instance"`
+	PostID   int64  `json:"postId"`
+	Count    int    `json:"count"`
+}
+
+type viewerShare struct {
+	count   int
+	updated time.Time
+}
+
+// viewerCounts adds up the viewers of every post over all instances: the
+// ones connected here and the shares other instances publish on the bus.
+type viewerCounts struct {
+	instance string
+
+	mu     sync.Mutex
+	local  map[int64]int
+	remote map[int64]map[string]viewerShare
+	// changed holds the posts whose local count was not shared yet.
+	changed map[int64]struct{}
+
+	// wake has a buffer of one so that a local change never waits for
+	// ShareViewerCounts.
+	wake chan struct{}
+}
+
+func newViewerCounts() *viewerCounts {
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+
+	return &viewerCounts{
+		instance: hex.EncodeToString(id),
+		local:    make(map[int64]int),
+		remote:   make(map[int64]map[string]viewerShare),
+		changed:  make(map[int64]struct{}),
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// total must be called with v.mu held.
+func (v *viewerCounts) total(postID int64) int {
+	total := v.local[postID]
+	for _, share := range v.remote[postID] {
+		total += share.count
+	}
+	return total
+}
+
+// onLocalCount is called by the comments hub with the hub locked, so it
+// leaves publishing the change on the bus to ShareViewerCounts.
+func (s *PostService) onLocalCount(postID int64, count int) {
+	v := s.viewerCounts
+
+	v.mu.Lock()
+	if count == 0 {
+		delete(v.local, postID)
+	} else {
+		v.local[postID] = count
+	}
+	v.changed[postID] = struct{}{}
+	s.viewers.Publish(postID, v.total(postID))
+	v.mu.Unlock()
+
+	select {
+	case v.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *PostService) onViewerCount(payload []byte) {
+	var event viewerCountEvent
+	if err := json.Unmarshal(payload, &event); err != nil || event.Instance == s.viewerCounts.instance {
+		return
+	}
+
+	v := s.viewerCounts
+
+	v.mu.Lock()
+	before := v.total(event.PostID)
+	if event.Count == 0 {
+		delete(v.remote[event.PostID], event.Instance)
+		if len(v.remote[event.PostID]) == 0 {
+			delete(v.remote, event.PostID)
+		}
+	} else {
+		if v.remote[event.PostID] == nil {
+			v.remote[event.PostID] = make(map[string]viewerShare)
+		}
+		v.remote[event.PostID][event.Instance] = viewerShare{count: event.Count, updated: time.Now()}
+	}
+	if total := v.total(event.PostID); total != before {
+		s.viewers.Publish(event.PostID, total)
+	}
+	v.mu.Unlock()
+}
+
+// ShareViewerCounts publishes the viewer counts of this instance on the event
+// bus until ctx is done: changes as they happen and every count periodically,
+// which keeps it from expiring on the other instances.
+func (s *PostService) ShareViewerCounts(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.ViewerCountInterval)
+	defer ticker.Stop()
+
+	v := s.viewerCounts
+	for {
+		var events []viewerCountEvent
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-v.wake:
+			v.mu.Lock()
+			for postID := range v.changed {
+				events = append(events, viewerCountEvent{Instance: v.instance, PostID: postID, Count: v.local[postID]})
+			}
+			clear(v.changed)
+			v.mu.Unlock()
+		case now := <-ticker.C:
+			v.mu.Lock()
+			for postID, count := range v.local {
+				events = append(events, viewerCountEvent{Instance: v.instance, PostID: postID, Count: count})
+			}
+			s.expireViewerShares(now)
+			v.mu.Unlock()
+		}
+
+		for _, event := range events {
+			// The bus logs the failure, the next heartbeat retries.
+			_ = s.publish(ctx, topicViewerCount, event)
+		}
+	}
+}
+
+// expireViewerShares drops the shares that were not refreshed in time. It
+// must be called with s.viewerCounts.mu held.
+func (s *PostService) expireViewerShares(now time.Time) {
+	v := s.viewerCounts
+	deadline := now.Add(-viewerShareTTL * s.cfg.ViewerCountInterval)
+
+	for postID, shares := range v.remote {
+		expired := false
+		for instance, share := range shares {
+			if share.updated.Before(deadline) {
+				delete(shares, instance)
+				expired = true
+			}
+		}
+		if len(shares) == 0 {
+			delete(v.remote, postID)
+		}
+		if expired {
+			s.viewers.Publish(postID, v.total(postID))
+		}
+	}
+}
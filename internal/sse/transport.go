@@ -0,0 +1,288 @@
+package sse
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// Transport serves operations over Server-Sent Events in the distinct
+// connections mode of the graphql-sse protocol: every operation gets its own
+// stream of next events terminated by a complete event. GET requests are
+// supported so that a browser EventSource can subscribe and reconnect.
+type Transport struct {
+	// HeartbeatInterval is how often a comment is written to an idle stream
+	// so that proxies do not close it. Zero disables heartbeats.
+	HeartbeatInterval time.Duration
+	// Resumable names the subscription fields that resume after the id given
+	// as Last-Event-ID. Only their events are sent with an id.
+	Resumable []string
+}
+
+var _ graphql.Transport = Transport{}
+
+func (t Transport) Supports(r *http.Request) bool {
+	if !strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		return false
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		return true
+	case http.MethodPost:
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		return err == nil && mediaType == "application/json"
+	default:
+		return false
+	}
+}
+
+func (t Transport) Do(w http.ResponseWriter, r *http.Request, exec graphql.GraphExecutor) {
+	ctx := r.Context()
+
+	start := graphql.Now()
+	params, err := readParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, exec.DispatchError(ctx, gqlerror.List{gqlerror.Errorf("%v", err)}))
+		return
+	}
+	params.Headers = r.Header
+	params.ReadTime = graphql.TraceTiming{
+		Start: start,
+		End:   graphql.Now(),
+	}
+
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		ctx = context.WithValue(ctx, lastEventIDKey{}, id)
+	}
+
+	opCtx, opErr := exec.CreateOperationContext(ctx, params)
+	ctx = graphql.WithOperationContext(ctx, opCtx)
+
+	// Like the GET transport, a GET request must not change anything, it can
+	// be sent by a link or a prefetch.
+	if opErr == nil && r.Method == http.MethodGet &&
+		opCtx.Operation.Operation != ast.Query && opCtx.Operation.Operation != ast.Subscription {
+		writeError(w, http.StatusNotAcceptable, exec.DispatchError(ctx,
+			gqlerror.List{gqlerror.Errorf("GET requests only allow query and subscription operations")}))
+		return
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	s := &stream{w: w, rc: http.NewResponseController(w)}
+	if opErr == nil {
+		s.rootKey, s.idKey = t.resumeKeys(opCtx.Operation)
+	}
+	if err := s.heartbeat(); err != nil {
+		return
+	}
+
+	if opErr != nil {
+		if err := s.next(exec.DispatchError(ctx, opErr)); err == nil {
+			_ = s.complete()
+		}
+		return
+	}
+
+	responses, ctx := exec.DispatchOperation(ctx, opCtx)
+
+	// responses blocks until the next event, so it is read on its own
+	// goroutine to keep sending heartbeats meanwhile.
+	results := make(chan *graphql.Response)
+	go func() {
+		defer close(results)
+		for {
+			resp := responses(ctx)
+			if resp == nil {
+				return
+			}
+			// The executor reuses the buffer behind Data for the next event.
+			resp.Data = append(json.RawMessage(nil), resp.Data...)
+
+			select {
+			case results <- resp:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var heartbeat <-chan time.Time
+	if t.HeartbeatInterval > 0 {
+		ticker := time.NewTicker(t.HeartbeatInterval)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+
+	for {
+		select {
+		case resp, ok := <-results:
+			if !ok {
+				_ = s.complete()
+				return
+			}
+			if err := s.next(resp); err != nil {
+				return
+			}
+		case <-heartbeat:
+			if err := s.heartbeat(); err != nil {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, resp *graphql.Response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func readParams(r *http.Request) (*graphql.RawParams, error) {
+	params := &graphql.RawParams{}
+
+	if r.Method == http.MethodGet {
+		query := r.URL.Query()
+		params.Query = query.Get("query")
+		params.OperationName = query.Get("operationName")
+
+		if variables := query.Get("variables"); variables != "" {
+			if err := decode(strings.NewReader(variables), &params.Variables); err != nil {
+				return nil, fmt.Errorf("variables could not be decoded: %v", err)
+			}
+		}
+		if extensions := query.Get("extensions"); extensions != "" {
+			if err := decode(strings.NewReader(extensions), &params.Extensions); err != nil {
+				return nil, fmt.Errorf("extensions could not be decoded: %v", err)
+			}
+		}
+
+		return params, nil
+	}
+
+	if err := decode(r.Body, params); err != nil {
+		return nil, fmt.Errorf("json request body could not be decoded: %v", err)
+	}
+
+	return params, nil
+}
+
+func decode(r io.Reader, v any) error {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// resumeKeys returns the response keys of the root field and of its id when
+// the operation subscribes to a resumable field and selects its id directly.
+func (t Transport) resumeKeys(op *ast.OperationDefinition) (root, id string) {
+	if op == nil || op.Operation != ast.Subscription || len(op.SelectionSet) != 1 {
+		return "", ""
+	}
+
+	field, ok := op.SelectionSet[0].(*ast.Field)
+	if !ok || !slices.Contains(t.Resumable, field.Name) {
+		return "", ""
+	}
+	for _, selection := range field.SelectionSet {
+		if f, ok := selection.(*ast.Field); ok && f.Name == "id" {
+			return responseKey(field), responseKey(f)
+		}
+	}
+
+	return "", ""
+}
+
+func responseKey(field *ast.Field) string {
+	if field.Alias != "" {
+		return field.Alias
+	}
+	return field.Name
+}
+
+type stream struct {
+	w  io.Writer
+	rc *http.ResponseController
+
+	// rootKey and idKey locate the id of the object sent by each event, see
+	// Transport.Resumable. Both are empty if events have no id.
+	rootKey, idKey string
+}
+
+func (s *stream) next(resp *graphql.Response) error {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+
+	if id := eventID(resp, s.rootKey, s.idKey); id != "" {
+		if _, err := fmt.Fprintf(s.w, "id: %s\n", id); err != nil {
+			return err
+		}
+	}
+
+	return s.write("event: next\ndata: %s\n\n", data)
+}
+
+func (s *stream) complete() error {
+	return s.write("event: complete\ndata:\n\n")
+}
+
+func (s *stream) heartbeat() error {
+	return s.write(":\n\n")
+}
+
+func (s *stream) write(format string, args ...any) error {
+	if _, err := fmt.Fprintf(s.w, format, args...); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+// eventID is the id of the object sent by a subscription event, found under
+// rootKey and idKey. A client reconnecting with it as Last-Event-ID lets the
+// subscription resume after that object.
+func eventID(resp *graphql.Response, rootKey, idKey string) string {
+	if rootKey == "" {
+		return ""
+	}
+
+	var root map[string]json.RawMessage
+	if err := json.Unmarshal(resp.Data, &root); err != nil {
+		return ""
+	}
+
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(root[rootKey], &object); err != nil || object[idKey] == nil {
+		return ""
+	}
+
+	return strings.Trim(string(object[idKey]), `"`)
+}
+
+type lastEventIDKey struct{}
+
+// LastEventID returns the Last-Event-ID sent by a reconnecting SSE client,
+// or an empty string.
+func LastEventID(ctx context.Context) string {
+	id, _ := ctx.Value(lastEventIDKey{}).(string)
+	return id
+}
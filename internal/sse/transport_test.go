@@ -0,0 +1,389 @@
+package sse_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AEKDA/ozon_task/internal/api/graph"
+	"github.com/AEKDA/ozon_task/internal/api/graph/model"
+	"github.com/AEKDA/ozon_task/internal/auth"
+	"github.com/AEKDA/ozon_task/internal/dataloader"
+	"github.com/AEKDA/ozon_task/internal/persisted"
+	"github.com/AEKDA/ozon_task/internal/pubsub"
+	"github.com/AEKDA/ozon_task/internal/repository/inmemory"
+	"github.com/AEKDA/ozon_task/internal/server"
+	"github.com/AEKDA/ozon_task/internal/service"
+)
+
+const timeout = 5 * time.Second
+
+type testServer struct {
+	*httptest.Server
+	posts *service.PostService
+	db    *inmemory.InMemoryDB
+	// ctx authenticates calls to posts as a registered user.
+	ctx context.Context
+}
+
+func newTestServer(t *testing.T, heartbeat time.Duration) *testServer {
+	t.Helper()
+
+	db := inmemory.NewInMemoryDB()
+	posts := service.NewPostService(db, db, db, pubsub.NewLocalBus(),
+		pubsub.Config{BufferSize: 16, Overflow: pubsub.DropOldest},
+		service.Config{MaxPageSize: 100, ViewerCountInterval: time.Second})
+
+	verifier, err := auth.NewVerifier(auth.Config{HMACSecret: "test-secret"})
+	if err != nil {
+		t.Fatalf("NewVerifier: %v", err)
+	}
+
+	resolver := &graph.Resolver{
+		PostService:       posts,
+		UserService:       service.NewUserService(db),
+		ModerationService: service.NewModerationService(db),
+	}
+	srv := server.New(resolver, dataloader.Extension{Repo: db}, verifier,
+		persisted.AutomaticPersistedQuery{Cache: persisted.NewMemoryCache(100)},
+		server.Config{HeartbeatInterval: heartbeat, ComplexityLimit: 10000, MaxDepth: 15}, "", 0)
+
+	ts := httptest.NewServer(auth.Middleware(verifier, srv.Handler))
+	t.Cleanup(ts.Close)
+
+	user, err := db.AddUser(context.Background(), 1, model.RegisterUserInput{Name: "alice"})
+	if err != nil {
+		t.Fatalf("AddUser: %v", err)
+	}
+
+	return &testServer{
+		Server: ts,
+		posts:  posts,
+		db:     db,
+		ctx:    auth.WithPrincipal(context.Background(), &auth.Principal{UserID: user.ID, Role: auth.RoleUser}),
+	}
+}
+
+func (s *testServer) addPost(t *testing.T) int64 {
+	t.Helper()
+
+	post, err := s.posts.AddPost(s.ctx, model.AddPostInput{Title: "title", Content: "content", AllowComments: true})
+	if err != nil {
+		t.Fatalf("AddPost: %v", err)
+	}
+	return post.ID
+}
+
+func (s *testServer) addComment(t *testing.T, postID int64, content string) int64 {
+	t.Helper()
+
+	comment, err := s.posts.AddCommentToPost(s.ctx, model.AddCommentInput{PostID: postID, Content: content})
+	if err != nil {
+		t.Fatalf("AddCommentToPost: %v", err)
+	}
+	return comment.ID
+}
+
+// get sends query as an EventSource would.
+func (s *testServer) get(t *testing.T, ctx context.Context, query string, header http.Header) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL+"/query?"+url.Values{"query": {query}}.Encode(), nil)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+
+	return resp
+}
+
+// event is a server-sent event, or a comment when heartbeat is set.
+type event struct {
+	heartbeat bool
+	id        string
+	name      string
+	data      string
+}
+
+// events parses the stream of resp until it ends.
+func events(resp *http.Response) <-chan event {
+	ch := make(chan event)
+	go func() {
+		defer close(ch)
+
+		r := bufio.NewReader(resp.Body)
+		var e event
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimSuffix(line, "\n")
+
+			switch {
+			case line == "":
+				ch <- e
+				e = event{}
+			case strings.HasPrefix(line, ":"):
+				e.heartbeat = true
+			case strings.HasPrefix(line, "id: "):
+				e.id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				e.name = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data:"):
+				e.data = strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+			}
+		}
+	}()
+	return ch
+}
+
+// next returns the next event that is not a heartbeat.
+func next(t *testing.T, ch <-chan event) event {
+	t.Helper()
+
+	deadline := time.After(timeout)
+	for {
+		select {
+		case e, ok := <-ch:
+			if !ok {
+				t.Fatal("stream ended")
+			}
+			if !e.heartbeat {
+				return e
+			}
+		case <-deadline:
+			t.Fatalf("no event after %v", timeout)
+		}
+	}
+}
+
+func TestHeartbeat(t *testing.T) {
+	s := newTestServer(t, 10*time.Millisecond)
+	postID := s.addPost(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	resp := s.get(t, ctx, "subscription { commentAdded(postId: "+strconv.FormatInt(postID, 10)+") { id } }", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if contentType := resp.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Errorf("Content-Type %q, want text/event-stream", contentType)
+	}
+
+	// The first heartbeat is sent right away, the others while idle.
+	ch := events(resp)
+	for i := 0; i < 3; i++ {
+		select {
+		case e, ok := <-ch:
+			if !ok {
+				t.Fatal("stream ended")
+			}
+			if !e.heartbeat {
+				t.Fatalf("got %+v, want a heartbeat", e)
+			}
+		case <-time.After(timeout):
+			t.Fatalf("no heartbeat after %v", timeout)
+		}
+	}
+}
+
+func TestLastEventIDResumes(t *testing.T) {
+	s := newTestServer(t, time.Hour)
+	postID := s.addPost(t)
+
+	seen := s.addComment(t, postID, "seen")
+	missed := []int64{s.addComment(t, postID, "missed 1"), s.addComment(t, postID, "missed 2")}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// A reconnecting EventSource sends the id of the last event it received.
+	resp := s.get(t, ctx, "subscription { commentAdded(postId: "+strconv.FormatInt(postID, 10)+") { id content } }",
+		http.Header{"Last-Event-ID": {strconv.FormatInt(seen, 10)}})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	ch := events(resp)
+
+	assertComment := func(e event, id int64) {
+		t.Helper()
+
+		if e.name != "next" || e.id != strconv.FormatInt(id, 10) {
+			t.Fatalf("got event %q with id %q, want next with id %d", e.name, e.id, id)
+		}
+
+		var payload struct {
+			Data struct {
+				CommentAdded struct {
+					ID json.Number `json:"id"`
+				} `json:"commentAdded"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal([]byte(e.data), &payload); err != nil {
+			t.Fatalf("decode %q: %v", e.data, err)
+		}
+		if payload.Data.CommentAdded.ID.String() != strconv.FormatInt(id, 10) {
+			t.Errorf("got comment %s, want %d", payload.Data.CommentAdded.ID, id)
+		}
+	}
+
+	for _, id := range missed {
+		assertComment(next(t, ch), id)
+	}
+
+	live := s.addComment(t, postID, "live")
+	assertComment(next(t, ch), live)
+}
+
+func TestEventIDs(t *testing.T) {
+	s := newTestServer(t, time.Hour)
+	postID := s.addPost(t)
+	parentID := s.addComment(t, postID, "parent")
+	post, parent := strconv.FormatInt(postID, 10), strconv.FormatInt(parentID, 10)
+
+	tests := []struct {
+		name  string
+		query string
+		// send makes the subscription emit an event and returns its id.
+		send func() int64
+		// resumable is whether events are sent with that id.
+		resumable bool
+	}{
+		{
+			name:      "aliased",
+			query:     "subscription { added: commentAdded(postId: " + post + ") { commentId: id content } }",
+			send:      func() int64 { return s.addComment(t, postID, "aliased") },
+			resumable: true,
+		},
+		{
+			name:  "id in a fragment",
+			query: "subscription { commentAdded(postId: " + post + ") { ... on Comment { id } } }",
+			send:  func() int64 { return s.addComment(t, postID, "fragment") },
+		},
+		{
+			name:  "not resumable",
+			query: "subscription { replyAdded(commentId: " + parent + ") { id } }",
+			send: func() int64 {
+				reply, err := s.posts.AddReplyToComment(s.ctx, model.AddReplyInput{CommentID: parentID, Content: "reply"})
+				if err != nil {
+					t.Fatalf("AddReplyToComment: %v", err)
+				}
+				return reply.ID
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			resp := s.get(t, ctx, tt.query, nil)
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("status %d, want %d", resp.StatusCode, http.StatusOK)
+			}
+			ch := events(resp)
+
+			// The stream does not tell when the subscription is set up, so
+			// events are sent until one arrives.
+			sent := make(map[string]bool)
+			ticker := time.NewTicker(10 * time.Millisecond)
+			defer ticker.Stop()
+			deadline := time.After(timeout)
+
+			var e event
+			for e.name == "" {
+				select {
+				case e = <-ch:
+				case <-ticker.C:
+					sent[strconv.FormatInt(tt.send(), 10)] = true
+				case <-deadline:
+					t.Fatalf("no event after %v", timeout)
+				}
+			}
+
+			if e.name != "next" {
+				t.Fatalf("got event %q, want next", e.name)
+			}
+			if tt.resumable && !sent[e.id] {
+				t.Errorf("event has id %q, want the id of a sent comment", e.id)
+			}
+			if !tt.resumable && e.id != "" {
+				t.Errorf("event has id %q, want none", e.id)
+			}
+		})
+	}
+}
+
+func TestGETRejectsMutations(t *testing.T) {
+	s := newTestServer(t, time.Hour)
+
+	resp := s.get(t, context.Background(), `mutation { registerUser(input: {name: "mallory"}) { id } }`, nil)
+	if resp.StatusCode != http.StatusNotAcceptable {
+		t.Fatalf("status %d, want %d", resp.StatusCode, http.StatusNotAcceptable)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read body: %v", err)
+	}
+	if !strings.Contains(string(body), "GET requests only allow query and subscription operations") {
+		t.Errorf("body %s does not explain the rejection", body)
+	}
+
+	// alice is the only user.
+	users, err := s.db.GetUsersByIDs(context.Background(), []int64{1, 2})
+	if err != nil {
+		t.Fatalf("GetUsersByIDs: %v", err)
+	}
+	if len(users) != 1 {
+		t.Errorf("the mutation was executed, users are %v", users)
+	}
+}
+
+func TestPOSTQuery(t *testing.T) {
+	s := newTestServer(t, time.Hour)
+	postID := s.addPost(t)
+
+	body := `{"query": "query { post(id: ` + strconv.FormatInt(postID, 10) + `) { id title } }"}`
+	req, err := http.NewRequest(http.MethodPost, s.URL+"/query", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST: %v", err)
+	}
+	defer resp.Body.Close()
+
+	ch := events(resp)
+	if e := next(t, ch); e.name != "next" || !strings.Contains(e.data, `"title":"title"`) {
+		t.Errorf("got %+v, want the post", e)
+	}
+	if e := next(t, ch); e.name != "complete" {
+		t.Errorf("got %+v, want complete", e)
+	}
+}
@@ -0,0 +1 @@
+CREATE INDEX comments_reply_to_idx ON comments (reply_to);
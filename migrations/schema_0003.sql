@@ -0,0 +1,2 @@
+CREATE INDEX posts_created_at_idx ON posts (created_at, id);
+CREATE INDEX comments_post_id_created_at_idx ON comments (post_id, created_at);
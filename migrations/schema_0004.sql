@@ -0,0 +1,2 @@
+CREATE INDEX posts_author_created_at_idx ON posts (author, created_at, id);
+CREATE INDEX posts_allow_comments_created_at_idx ON posts (allow_comments, created_at, id);
@@ -0,0 +1,8 @@
+ALTER TABLE posts ADD COLUMN search_vector tsvector
+    GENERATED ALWAYS AS (to_tsvector('simple', title || ' ' || content)) STORED;
+
+ALTER TABLE comments ADD COLUMN search_vector tsvector
+    GENERATED ALWAYS AS (to_tsvector('simple', content)) STORED;
+
+CREATE INDEX posts_search_vector_idx ON posts USING GIN (search_vector);
+CREATE INDEX comments_search_vector_idx ON comments USING GIN (search_vector);
@@ -0,0 +1,4 @@
+ALTER TABLE posts ADD COLUMN edited_at TIMESTAMPTZ;
+
+ALTER TABLE comments ADD COLUMN edited_at TIMESTAMPTZ;
+ALTER TABLE comments ADD COLUMN deleted_at TIMESTAMPTZ;
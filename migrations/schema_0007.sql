@@ -0,0 +1,26 @@
+ALTER TABLE posts ADD COLUMN revision INT NOT NULL DEFAULT 1;
+ALTER TABLE comments ADD COLUMN revision INT NOT NULL DEFAULT 1;
+
+CREATE TABLE post_revisions (
+    post_id integer REFERENCES posts ON DELETE CASCADE not null,
+    revision INT NOT NULL,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    author TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (post_id, revision)
+);
+
+CREATE TABLE comment_revisions (
+    comment_id integer REFERENCES comments ON DELETE CASCADE not null,
+    revision INT NOT NULL,
+    content TEXT NOT NULL,
+    author TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (comment_id, revision)
+);
+
+INSERT INTO post_revisions (post_id, revision, title, content, author, created_at)
+    SELECT id, revision, title, content, author, coalesce(edited_at, created_at) FROM posts;
+INSERT INTO comment_revisions (comment_id, revision, content, author, created_at)
+    SELECT id, revision, content, author, coalesce(edited_at, created_at) FROM comments WHERE deleted_at IS NULL;
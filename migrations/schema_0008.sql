@@ -0,0 +1,32 @@
+CREATE TABLE users (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO users (name)
+    SELECT author FROM posts
+    UNION SELECT author FROM comments
+    UNION SELECT author FROM post_revisions
+    UNION SELECT author FROM comment_revisions;
+
+ALTER TABLE posts ADD COLUMN author_id integer REFERENCES users;
+UPDATE posts SET author_id = users.id FROM users WHERE users.name = posts.author;
+ALTER TABLE posts ALTER COLUMN author_id SET NOT NULL;
+ALTER TABLE posts DROP COLUMN author;
+CREATE INDEX posts_author_id_created_at_idx ON posts (author_id, created_at, id);
+
+ALTER TABLE comments ADD COLUMN author_id integer REFERENCES users;
+UPDATE comments SET author_id = users.id FROM users WHERE users.name = comments.author;
+ALTER TABLE comments ALTER COLUMN author_id SET NOT NULL;
+ALTER TABLE comments DROP COLUMN author;
+
+ALTER TABLE post_revisions ADD COLUMN author_id integer REFERENCES users;
+UPDATE post_revisions SET author_id = users.id FROM users WHERE users.name = post_revisions.author;
+ALTER TABLE post_revisions ALTER COLUMN author_id SET NOT NULL;
+ALTER TABLE post_revisions DROP COLUMN author;
+
+ALTER TABLE comment_revisions ADD COLUMN author_id integer REFERENCES users;
+UPDATE comment_revisions SET author_id = users.id FROM users WHERE users.name = comment_revisions.author;
+ALTER TABLE comment_revisions ALTER COLUMN author_id SET NOT NULL;
+ALTER TABLE comment_revisions DROP COLUMN author;
@@ -0,0 +1,6 @@
+ALTER TABLE users ADD COLUMN banned_until TIMESTAMPTZ;
+
+ALTER TABLE comments ADD COLUMN hidden BOOLEAN NOT NULL DEFAULT false;
+
+ALTER TABLE posts ADD COLUMN locked BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE posts ADD COLUMN pinned_comment_id integer REFERENCES comments ON DELETE SET NULL;
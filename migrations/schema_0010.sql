@@ -0,0 +1,5 @@
+CREATE TABLE persisted_queries (
+    hash TEXT PRIMARY KEY,
+    query TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,3 @@
+-- User ids are the subjects of the bearer tokens, so they are never generated.
+ALTER TABLE users ALTER COLUMN id DROP DEFAULT;
+DROP SEQUENCE users_id_seq;